@@ -1,124 +1,92 @@
 package main
 
 import (
-	"bytes"
-	"errors"
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
-	"strings"
-	"text/template"
 
 	microblog "github.com/felix-schott/microblog-gen/pkg"
-	"github.com/yosssi/gohtml"
 )
 
-// Recursively creates hard links between the contents of src to the directory dst.
-// The function creates dst if necessary.
-func overwriteDirectoryContents(src string, dst string, force bool) error {
-	stat, err := os.Stat(src)
-	if err != nil {
-		return fmt.Errorf("failed to check if %v is a directory: %v", src, err)
-	}
-	if !stat.IsDir() {
-		return fmt.Errorf("%v is not a directory", src)
-	}
-	dstContents, err := os.ReadDir(dst)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			return err
-		}
-	}
-	if len(dstContents) != 0 && !force {
-		return fmt.Errorf("directory %v is not empty. use flag force to overwrite", dst)
-	}
-	if err := os.RemoveAll(dst); err != nil {
-		return fmt.Errorf("could not remove directory %v: %v", dst, err)
-	}
-	if err := os.MkdirAll(dst, 0755); err != nil {
-		return fmt.Errorf("could not create directory %v: %v", dst, err)
-	}
-	srcContents, err := os.ReadDir(src)
-	if err != nil {
-		return fmt.Errorf("could not read the directory %v: %v", src, err)
-	}
-	for _, f := range srcContents {
-		if f.IsDir() {
-			if err := overwriteDirectoryContents(filepath.Join(src, f.Name()), filepath.Join(dst, f.Name()), force); err != nil {
-				return err
-			}
-		} else if !strings.HasSuffix(f.Name(), ".tmpl") { // ignore template files, they're handled separately
-			from := filepath.Join(src, f.Name())
-			to := filepath.Join(dst, f.Name())
-			if err := os.Link(from, to); err != nil {
-				return fmt.Errorf("could not create a hard link from %v to %v: %v", from, to, err)
-			}
-		}
-	}
-	return nil
-}
-
 type buildOptions struct {
 	Force            bool
 	PostTemplateFile string
+	SortOrder        microblog.SortOrder
+	Sections         bool
+	Drafts           bool
+	RelatedPosts     int    // number of related posts computed per post
+	PageSize         int    // number of posts per index page, 0 disables pagination
+	Permalinks       bool   // generate a page per post at posts/<slug>/index.html
+	RemoveExpired    bool   // don't generate permalink pages for expired posts, which are generated even without Permalinks
+	Archive          bool   // generate archive pages, implies Permalinks
+	Workers          int    // maximum number of posts rendered concurrently, defaults to the number of CPUs
+	NoCache          bool   // render all posts instead of reusing the HTML of unchanged posts from the last build
+	NoFormat         bool   // write the generated HTML as is, which allows streaming the posts into index.html
+	Title            string // title of the website, available as {{.Site.Title}} in the page template
+	BaseURL          string // URL the website is served from, available as {{.Site.BaseURL}} in the page template
 }
 
-func build(sourceDirectory string, blogDirectory string, outputDirectory string, options buildOptions) error {
-	if err := overwriteDirectoryContents(sourceDirectory, outputDirectory, options.Force); err != nil {
-		return err
+// Returns the options for microblog.NewBlog and microblog.NewBlogFS that correspond to the build options. The
+// layouts and partials in source are parsed together with the post template, which can use the template functions
+// of microblog.TemplateFuncs with absURL resolving against BaseURL.
+func (o buildOptions) blogOptions(source fs.FS) []microblog.Option {
+	blogOptions := []microblog.Option{
+		microblog.WithPartials(source, microblog.PartialPatterns...),
+		microblog.WithBaseURL(o.BaseURL),
 	}
-
-	var blog microblog.Blog
-	var err error
-	// read blog posts, markdown to html
-	if options.PostTemplateFile != "" {
-		blog, err = microblog.NewBlog(blogDirectory, microblog.WithTemplateFile(options.PostTemplateFile), microblog.WithPublicationTracking())
-	} else {
-		blog, err = microblog.NewBlog(blogDirectory, microblog.WithPublicationTracking())
+	if o.PostTemplateFile != "" {
+		blogOptions = append(blogOptions, microblog.WithTemplateFile(o.PostTemplateFile))
 	}
-	if err != nil {
-		return fmt.Errorf("error when initialising blog: %v", err)
+	if o.SortOrder != "" {
+		blogOptions = append(blogOptions, microblog.WithSortOrder(o.SortOrder))
 	}
-
-	blogPostsHtml, err := blog.RenderPosts()
-	if err != nil {
-		return fmt.Errorf("error when trying to render html: %v", err)
+	if o.Sections {
+		blogOptions = append(blogOptions, microblog.WithSections())
 	}
-
-	// build index.html
-	matches, err := filepath.Glob(filepath.Join(sourceDirectory, "*.html.tmpl"))
-	if err != nil {
-		return fmt.Errorf("error when trying to match template files: %v", err)
+	if o.Drafts {
+		blogOptions = append(blogOptions, microblog.WithDrafts())
 	}
-	if len(matches) != 1 {
-		return fmt.Errorf("expected exactly 1 template file (*.html.tmpl) in %v, got %v", sourceDirectory, len(matches))
+	if o.RelatedPosts > 0 {
+		blogOptions = append(blogOptions, microblog.WithRelatedPosts(o.RelatedPosts))
 	}
-	tmplBytes, err := os.ReadFile(matches[0])
-	if err != nil {
-		return fmt.Errorf("could not read file %v: %v", matches[0], err)
+	return blogOptions
+}
+
+// Returns the options for microblog.BuildSite that correspond to the build options.
+func (o buildOptions) siteOptions() microblog.SiteOptions {
+	return microblog.SiteOptions{
+		Force:         o.Force,
+		PageSize:      o.PageSize,
+		Permalinks:    o.Permalinks,
+		RemoveExpired: o.RemoveExpired,
+		Archive:       o.Archive,
+		Workers:       o.Workers,
+		NoFormat:      o.NoFormat,
+		Title:         o.Title,
+		BaseURL:       o.BaseURL,
 	}
-	tmpl, err := template.New("index.html").Parse(string(tmplBytes))
+}
+
+// Builds the website from the source and blog directories on disk. The publication dates of the posts are tracked
+// in the blog directory, which also holds the render cache.
+func build(ctx context.Context, sourceDirectory string, blogDirectory string, outputDirectory string, options buildOptions) error {
+	stat, err := os.Stat(sourceDirectory)
 	if err != nil {
-		return fmt.Errorf("could not open template: %v", err)
+		return fmt.Errorf("failed to check if %v is a directory: %v", sourceDirectory, err)
 	}
-	if tmpl.Tree == nil {
-		return errors.New("template tree is empty")
-	}
-	outputFp := filepath.Join(outputDirectory, "index.html")
-	outputFile, err := os.Create(outputFp)
-	if err != nil {
-		return fmt.Errorf("could not open output file %v: %v", outputFp, err)
+	if !stat.IsDir() {
+		return fmt.Errorf("%v is not a directory", sourceDirectory)
 	}
-	defer outputFile.Close()
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, string(blogPostsHtml)); err != nil {
-		return fmt.Errorf("could not generate output: %v", err)
+	// read blog posts, markdown to html
+	blogOptions := append(options.blogOptions(os.DirFS(sourceDirectory)), microblog.WithPublicationTracking())
+	if !options.NoCache {
+		blogOptions = append(blogOptions, microblog.WithRenderCache())
 	}
-	formattedHtml := gohtml.FormatBytes(buf.Bytes())
-	if _, err := outputFile.Write(formattedHtml); err != nil {
-		return fmt.Errorf("could not write formatted html to %v: %v", outputFp, err)
+	blog, err := microblog.NewBlog(blogDirectory, blogOptions...)
+	if err != nil {
+		return fmt.Errorf("error when initialising blog: %w", err)
 	}
-
-	return nil
+	return microblog.BuildSite(ctx, os.DirFS(sourceDirectory), blog, outputDirectory, options.siteOptions())
 }
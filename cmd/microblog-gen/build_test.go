@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	microblog "github.com/felix-schott/microblog-gen/pkg"
 )
 
 func TestBuildEmptyDest(t *testing.T) {
@@ -29,7 +37,7 @@ func TestBuildEmptyDest(t *testing.T) {
 	postFp := filepath.Join(blog, "test1.md")
 	os.WriteFile(postFp, []byte("## Title\nhey [google](https://google.com)."), 0644)
 
-	if err := build(src, blog, out, buildOptions{Force: false}); err != nil {
+	if err := build(context.Background(), src, blog, out, buildOptions{Force: false}); err != nil {
 		t.Error("failed to build html with empty output directory:", err)
 	}
 
@@ -90,7 +98,7 @@ func TestBuildNonEmptyDestError(t *testing.T) {
 		}
 	`), 0644)
 
-	err := build(src, blog, out, buildOptions{Force: false})
+	err := build(context.Background(), src, blog, out, buildOptions{Force: false})
 	if err == nil {
 		t.Error("expected error")
 		t.FailNow()
@@ -130,7 +138,7 @@ func TestBuildNonEmptyDestForce(t *testing.T) {
 		}
 	`), 0644)
 
-	if err := build(src, blog, out, buildOptions{Force: true}); err != nil {
+	if err := build(context.Background(), src, blog, out, buildOptions{Force: true}); err != nil {
 		t.Error("failed to build html with existing output directory:", err)
 	}
 
@@ -170,3 +178,678 @@ func TestBuildNonEmptyDestForce(t *testing.T) {
 		t.Error("expected file to contain link to google")
 	}
 }
+
+func TestBuildSections(t *testing.T) {
+	src := t.TempDir()
+	out := t.TempDir()
+	blog := t.TempDir()
+
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`
+        <div class="blog">
+        	{{.Content}}
+        </div>
+	`), 0644)
+
+	// create dummy blog posts in two sections
+	os.MkdirAll(filepath.Join(blog, "notes"), 0755)
+	os.MkdirAll(filepath.Join(blog, "essays"), 0755)
+	os.WriteFile(filepath.Join(blog, "notes", "post.md"), []byte("## Note\nhey"), 0644)
+	os.WriteFile(filepath.Join(blog, "essays", "post.md"), []byte("## Essay\nhey"), 0644)
+
+	if err := build(context.Background(), src, blog, out, buildOptions{Sections: true}); err != nil {
+		t.Error("failed to build html with sections:", err)
+		t.FailNow()
+	}
+
+	indexHtml, err := os.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil {
+		t.Error("could not read index.html file:", err)
+	}
+	if !strings.Contains(string(indexHtml), "Note") || !strings.Contains(string(indexHtml), "Essay") {
+		t.Error("expected index.html to contain all posts, got", string(indexHtml))
+	}
+
+	notesHtml, err := os.ReadFile(filepath.Join(out, "notes", "index.html"))
+	if err != nil {
+		t.Error("could not read notes/index.html file:", err)
+	}
+	if !strings.Contains(string(notesHtml), "Note") || strings.Contains(string(notesHtml), "Essay") {
+		t.Error("expected notes/index.html to contain only the note, got", string(notesHtml))
+	}
+}
+
+func TestBuildTags(t *testing.T) {
+	src := t.TempDir()
+	out := t.TempDir()
+	blog := t.TempDir()
+
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`
+        <div class="blog">
+        	{{.Content}}
+        </div>
+	`), 0644)
+
+	os.WriteFile(filepath.Join(blog, "go.md"), []byte("---\ntags: [Go]\n---\n## Go post\nhey"), 0644)
+	os.WriteFile(filepath.Join(blog, "web.md"), []byte("---\ntags: [Go, Web]\n---\n## Web post\nhey"), 0644)
+
+	if err := build(context.Background(), src, blog, out, buildOptions{}); err != nil {
+		t.Error("failed to build html with tags:", err)
+		t.FailNow()
+	}
+
+	cloudHtml, err := os.ReadFile(filepath.Join(out, "tags", "index.html"))
+	if err != nil {
+		t.Error("could not read tags/index.html file:", err)
+	}
+	if !strings.Contains(string(cloudHtml), `<a href="/tags/go/">`) || !strings.Contains(string(cloudHtml), `<a href="/tags/web/">`) {
+		t.Error("expected tag cloud to link to all tags, got", string(cloudHtml))
+	}
+
+	webHtml, err := os.ReadFile(filepath.Join(out, "tags", "web", "index.html"))
+	if err != nil {
+		t.Error("could not read tags/web/index.html file:", err)
+	}
+	if !strings.Contains(string(webHtml), "Web post") || strings.Contains(string(webHtml), "Go post") {
+		t.Error("expected tags/web/index.html to contain only the web post, got", string(webHtml))
+	}
+
+	if _, err := os.Stat(filepath.Join(out, "categories")); err == nil {
+		t.Error("expected no category pages without categories")
+	}
+}
+
+func TestBuildPagination(t *testing.T) {
+	src := t.TempDir()
+	out := t.TempDir()
+	blog := t.TempDir()
+
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`
+        <div class="blog">
+        	{{.Content}}
+        </div>
+        <nav>
+        	<span class="current">{{.Pagination.Current}} of {{.Pagination.Total}}</span>
+        	{{with .Pagination.PrevURL}}<a class="prev" href="{{.}}">prev</a>{{end}}
+        	{{with .Pagination.NextURL}}<a class="next" href="{{.}}">next</a>{{end}}
+        </nav>
+	`), 0644)
+
+	for i := range 5 {
+		os.WriteFile(filepath.Join(blog, fmt.Sprintf("%03d.md", i+1)), []byte(fmt.Sprintf("## Post %v\nhey", i+1)), 0644)
+	}
+
+	if err := build(context.Background(), src, blog, out, buildOptions{SortOrder: microblog.SortByName, PageSize: 2}); err != nil {
+		t.Error("failed to build paginated html:", err)
+		t.FailNow()
+	}
+
+	for fp, expected := range map[string][]string{
+		"index.html":        {"Post 1", "Post 2", "1 of 3", `href="/page/2/"`},
+		"page/2/index.html": {"Post 3", "Post 4", "2 of 3", `class="prev" href="/"`, `class="next" href="/page/3/"`},
+		"page/3/index.html": {"Post 5", "3 of 3", `class="prev" href="/page/2/"`},
+	} {
+		html, err := os.ReadFile(filepath.Join(out, fp))
+		if err != nil {
+			t.Errorf("could not read %v: %v", fp, err)
+			continue
+		}
+		for _, e := range expected {
+			if !strings.Contains(string(html), e) {
+				t.Errorf("expected %v to contain %q, got %s", fp, e, html)
+			}
+		}
+	}
+
+	indexHtml, _ := os.ReadFile(filepath.Join(out, "index.html"))
+	if strings.Contains(string(indexHtml), "Post 3") || strings.Contains(string(indexHtml), `class="prev"`) {
+		t.Error("expected index.html to contain only the first page without link to a previous page, got", string(indexHtml))
+	}
+	if _, err := os.Stat(filepath.Join(out, "page", "4")); err == nil {
+		t.Error("expected no fourth page")
+	}
+}
+
+func TestBuildPermalinksExpired(t *testing.T) {
+	for _, tc := range []struct{ permalinks, removeExpired bool }{{true, false}, {true, true}, {false, false}, {false, true}} {
+		src := t.TempDir()
+		out := t.TempDir()
+		blog := t.TempDir()
+
+		os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`
+        <div class="blog">
+        	{{.Content}}
+        </div>
+		`), 0644)
+
+		os.WriteFile(filepath.Join(blog, "001_current.md"), []byte("## Current post\nhey"), 0644)
+		os.WriteFile(filepath.Join(blog, "002_announcement.md"), []byte("---\nexpires: 2024-01-01\n---\n## Announcement\nhey"), 0644)
+
+		if err := build(context.Background(), src, blog, out, buildOptions{Permalinks: tc.permalinks, RemoveExpired: tc.removeExpired}); err != nil {
+			t.Error("failed to build html with permalinks:", err)
+			t.FailNow()
+		}
+
+		indexHtml, err := os.ReadFile(filepath.Join(out, "index.html"))
+		if err != nil {
+			t.Error("could not read index.html file:", err)
+		}
+		if strings.Contains(string(indexHtml), "Announcement") {
+			t.Error("expected index.html to omit the expired post, got", string(indexHtml))
+		}
+
+		postHtml, err := os.ReadFile(filepath.Join(out, "posts", "current", "index.html"))
+		if !tc.permalinks {
+			if err == nil {
+				t.Error("expected no permalink page for the current post without -permalinks")
+			}
+		} else if err != nil || !strings.Contains(string(postHtml), "Current post") {
+			t.Errorf("expected permalink page for current post, got %s (%v)", postHtml, err)
+		}
+
+		// expired posts keep their permalink page unless they are removed, even without -permalinks
+		archivedHtml, err := os.ReadFile(filepath.Join(out, "posts", "announcement", "index.html"))
+		if tc.removeExpired {
+			if err == nil {
+				t.Error("expected no permalink page for the expired post")
+			}
+		} else if err != nil || !strings.Contains(string(archivedHtml), `class="archived-banner"`) {
+			t.Errorf("expected permalink page with archived notice for the expired post, got %s (%v)", archivedHtml, err)
+		}
+	}
+}
+
+func TestBuildSeries(t *testing.T) {
+	src := t.TempDir()
+	out := t.TempDir()
+	blog := t.TempDir()
+
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`
+        <div class="blog">
+        	{{.Content}}
+        </div>
+	`), 0644)
+
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("---\nseries: Tour\nseriesPosition: 2\n---\n## Second stop\nhey"), 0644)
+	os.WriteFile(filepath.Join(blog, "b.md"), []byte("---\nseries: Tour\nseriesPosition: 1\n---\n## First stop\nhey"), 0644)
+	os.WriteFile(filepath.Join(blog, "c.md"), []byte("## Unrelated\nhey"), 0644)
+
+	if err := build(context.Background(), src, blog, out, buildOptions{}); err != nil {
+		t.Error("failed to build html with series:", err)
+		t.FailNow()
+	}
+
+	seriesHtml, err := os.ReadFile(filepath.Join(out, "series", "tour", "index.html"))
+	if err != nil {
+		t.Error("could not read series/tour/index.html file:", err)
+	}
+	first, second := strings.Index(string(seriesHtml), "First stop"), strings.Index(string(seriesHtml), "Second stop")
+	if first == -1 || second == -1 || first > second || strings.Contains(string(seriesHtml), "Unrelated") {
+		t.Error("expected series page to list the posts of the series in order, got", string(seriesHtml))
+	}
+}
+
+func TestBuildArchive(t *testing.T) {
+	for _, customTemplate := range []bool{false, true} {
+		src := t.TempDir()
+		out := t.TempDir()
+		blog := t.TempDir()
+
+		os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`
+        <div class="blog">
+        	{{.Content}}
+        </div>
+		`), 0644)
+		if customTemplate {
+			os.WriteFile(filepath.Join(src, "archive.tmpl"), []byte(`
+			<h1>{{if .Year}}Archive {{.Year.Year}}{{else}}Archive{{end}}</h1>
+			{{range .Years}}<a class="year" href="{{.URL}}">{{.Year}} ({{.Count}})</a>{{end}}
+			{{with .Year}}{{range .Months}}<h2>{{.Month}}</h2>{{range .Entries}}<a href="{{.GetURL}}">{{.GetTitle}}</a>{{end}}{{end}}{{end}}
+			`), 0644)
+		}
+
+		os.WriteFile(filepath.Join(blog, "a.md"), []byte("---\ndate: 2023-12-24\n---\n## Holidays\nhey"), 0644)
+		os.WriteFile(filepath.Join(blog, "b.md"), []byte("---\ndate: 2024-01-05\n---\n## New year\nhey"), 0644)
+
+		if err := build(context.Background(), src, blog, out, buildOptions{Archive: true}); err != nil {
+			t.Error("failed to build html with archive:", err)
+			t.FailNow()
+		}
+
+		archiveHtml, err := os.ReadFile(filepath.Join(out, "archive", "index.html"))
+		if err != nil {
+			t.Error("could not read archive/index.html file:", err)
+		}
+		yearHtml, err := os.ReadFile(filepath.Join(out, "archive", "2024", "index.html"))
+		if err != nil {
+			t.Error("could not read archive/2024/index.html file:", err)
+		}
+		if _, err := os.Stat(filepath.Join(out, "posts", "a", "index.html")); err != nil {
+			t.Error("expected archive to generate permalink pages:", err)
+		}
+
+		if customTemplate {
+			if !strings.Contains(string(archiveHtml), `href="/archive/2023/"`) || !strings.Contains(string(archiveHtml), "2024 (1)") {
+				t.Error("expected archive overview to link to both years, got", string(archiveHtml))
+			}
+			if !strings.Contains(string(yearHtml), "Archive 2024") || !strings.Contains(string(yearHtml), "January") || !strings.Contains(string(yearHtml), `href="/posts/b/"`) {
+				t.Error("expected archive page for 2024 to list the post of January, got", string(yearHtml))
+			}
+			if _, err := os.Stat(filepath.Join(out, "archive.tmpl")); err == nil {
+				t.Error("expected archive template not to be copied to the output directory")
+			}
+			continue
+		}
+		if !strings.Contains(string(archiveHtml), "December") || !strings.Contains(string(archiveHtml), "January") || !strings.Contains(string(archiveHtml), `href="/posts/a/"`) {
+			t.Error("expected archive overview to list all posts by month, got", string(archiveHtml))
+		}
+		if strings.Contains(string(yearHtml), "December") || !strings.Contains(string(yearHtml), "New year") {
+			t.Error("expected archive page for 2024 to list only posts of 2024, got", string(yearHtml))
+		}
+	}
+}
+
+func TestBuildNoFormat(t *testing.T) {
+	src := t.TempDir()
+	blog := t.TempDir()
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("## First\nhey"), 0644)
+	os.WriteFile(filepath.Join(blog, "b.md"), []byte("## Second\nhello"), 0644)
+
+	for template, posts := range map[string]int{
+		`<main>{{.Content}}</main>`:                            2, // posts streamed into the page
+		`<main>{{.Content}}</main><aside>{{.Content}}</aside>`: 4, // posts inserted twice, rendered into memory
+	} {
+		os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(template), 0644)
+		out := t.TempDir()
+		if err := build(context.Background(), src, blog, out, buildOptions{NoFormat: true, Force: true}); err != nil {
+			t.Fatal(err)
+		}
+		indexHtml, err := os.ReadFile(filepath.Join(out, "index.html"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(indexHtml), "<main>\n\t<div class=\"blog-post\">") || !strings.Contains(string(indexHtml), "</div>\n\t</main>") {
+			t.Errorf("expected unformatted html, got %s", indexHtml)
+		}
+		if count := strings.Count(string(indexHtml), `<div class="blog-post">`); count != posts {
+			t.Errorf("expected %v posts in index.html, got %v: %s", posts, count, indexHtml)
+		}
+	}
+}
+
+func TestBuildNoFormatErrors(t *testing.T) {
+	src := t.TempDir()
+	blog := t.TempDir()
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`<main>{{.Content}}</main>`), 0644)
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("\nno heading"), 0644)
+	os.WriteFile(filepath.Join(blog, "b.md"), []byte("## Title\nhello"), 0644)
+	os.WriteFile(filepath.Join(blog, "c.md"), []byte("\nno heading either"), 0644)
+
+	// the posts are streamed in windows of one post, every failed post is reported
+	out := t.TempDir()
+	err := build(context.Background(), src, blog, out, buildOptions{NoFormat: true, Workers: 1, SortOrder: microblog.SortByName})
+	var messages []string
+	for _, e := range collectPostErrors(err) {
+		messages = append(messages, e.Error())
+	}
+	expected := []string{
+		filepath.Join(blog, "a.md") + ":2:1: no heading found",
+		filepath.Join(blog, "c.md") + ":2:1: no heading found",
+	}
+	if !slices.Equal(messages, expected) {
+		t.Errorf("expected errors %v, got %v", expected, messages)
+	}
+	entries, _ := os.ReadDir(out)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), "index.html") {
+			t.Errorf("expected no index.html after a failed build, got %v", e.Name())
+		}
+	}
+
+	// a cancelled build stops rendering and leaves no index.html behind either
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(blog, "c.md"), []byte("## Title\nhi"), 0644)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out = t.TempDir()
+	if err := build(ctx, src, blog, out, buildOptions{NoFormat: true}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the build to be cancelled, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "index.html")); err == nil {
+		t.Error("expected no index.html after a cancelled build")
+	}
+}
+
+func TestBuildSlugOutsideOutputDirectory(t *testing.T) {
+	src := t.TempDir()
+	out := filepath.Join(t.TempDir(), "build")
+	blog := t.TempDir()
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`{{.Content}}`), 0644)
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("---\nslug: ../../escaped\n---\n## Title\nhey"), 0644)
+
+	err := build(context.Background(), src, blog, out, buildOptions{Permalinks: true})
+	if err == nil {
+		t.Fatal("expected build to fail for a slug outside the output directory")
+	}
+	if errs := collectPostErrors(err); len(errs) != 1 || !strings.Contains(errs[0].Error(), "slug") {
+		t.Errorf("expected an error about the slug, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "..", "escaped")); err == nil {
+		t.Error("expected no page outside the output directory")
+	}
+}
+
+func TestBuildPostErrors(t *testing.T) {
+	src := t.TempDir()
+	out := t.TempDir()
+	blog := t.TempDir()
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`{{.Content}}`), 0644)
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("## Title\nhey\n\n## Another title"), 0644)
+	os.WriteFile(filepath.Join(blog, "b.md"), []byte("## Title\nhello"), 0644)
+	os.WriteFile(filepath.Join(blog, "c.md"), []byte("\nno heading"), 0644)
+
+	err := build(context.Background(), src, blog, out, buildOptions{Force: true})
+	if err == nil {
+		t.Fatal("expected build to fail")
+	}
+	var messages []string
+	for _, e := range collectPostErrors(err) {
+		messages = append(messages, e.Error())
+	}
+	expected := []string{
+		filepath.Join(blog, "a.md") + ":4:1: more than one heading in blog post",
+		filepath.Join(blog, "c.md") + ":2:1: no heading found",
+	}
+	if !slices.Equal(messages, expected) {
+		t.Errorf("expected errors %v, got %v", expected, messages)
+	}
+}
+
+func TestBuildErrorLeaves(t *testing.T) {
+	src := t.TempDir()
+	out := t.TempDir()
+	blog := t.TempDir()
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`{{.Content}}`), 0644)
+	os.WriteFile(filepath.Join(src, "post.tmpl"), []byte(`{{index .Tags 0}}`), 0644)
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("---\ntags: [go]\n---\nno heading"), 0644)
+	os.WriteFile(filepath.Join(blog, "b.md"), []byte("## Title\nhello"), 0644)
+
+	err := build(context.Background(), src, blog, out, buildOptions{PostTemplateFile: filepath.Join(src, "post.tmpl"), SortOrder: microblog.SortByName})
+	leaves := errorLeaves(err)
+	if len(leaves) != 2 {
+		t.Fatalf("expected an error for each post, got %v", leaves)
+	}
+	if !strings.HasPrefix(leaves[0].Error(), filepath.Join(blog, "a.md")+":4:1: no heading found") {
+		t.Errorf("expected missing heading in a.md, got %v", leaves[0])
+	}
+	var postErr *microblog.PostError
+	if !errors.As(leaves[1], &postErr) || postErr.Kind != microblog.KindTemplate || postErr.Path != filepath.Join(blog, "b.md") {
+		t.Errorf("expected template error in b.md, got %v", leaves[1])
+	}
+
+	// errors other than a *microblog.PostError are kept with their context
+	other := fmt.Errorf("could not read file x: %w", os.ErrNotExist)
+	if leaves := errorLeaves(errors.Join(leaves[0], other)); len(leaves) != 2 || leaves[1] != other {
+		t.Errorf("expected the post error and the other error, got %v", leaves)
+	}
+}
+
+func TestBuildSiteModel(t *testing.T) {
+	for _, noFormat := range []bool{false, true} {
+		src := t.TempDir()
+		out := t.TempDir()
+		blog := t.TempDir()
+
+		os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`
+        <title>{{with .Posts}}{{if eq (len .) 1}}{{(index . 0).GetTitle}} - {{end}}{{end}}{{.Site.Title}}</title>
+        <ul class="toc">{{range .Posts}}<li><a href="{{.GetURL}}">{{.GetTitle}}</a>|{{.DtPosted}}|{{len .Tags}}</li>{{end}}</ul>
+        <p class="count">{{.Site.PostCount}} posts, {{len .Site.Tags}} tags, built {{.BuildTime.Year}} at {{.Site.BaseURL}}</p>
+        {{with .Latest}}<p class="latest">{{.GetTitle}}</p>{{end}}
+        <div class="blog"><i class="posts"></i>{{.Content}}<i class="posts"></i></div>
+        <div class="html"><i class="posts"></i>{{range .Posts}}{{.HTML}}{{end}}<i class="posts"></i></div>
+		`), 0644)
+		os.WriteFile(filepath.Join(blog, "first.md"), []byte("---\ndate: 2024-01-01\ntags: [go]\n---\n## First\nhey"), 0644)
+		os.WriteFile(filepath.Join(blog, "second.md"), []byte("---\ndate: 2024-03-01\n---\n## Second\nhi"), 0644)
+
+		if err := build(context.Background(), src, blog, out, buildOptions{SortOrder: microblog.SortByDateDescending, Title: "My blog", BaseURL: "https://example.com/", Permalinks: true, NoFormat: noFormat}); err != nil {
+			t.Fatal("failed to build html:", err)
+		}
+		for fp, expected := range map[string][]string{
+			"index.html": {
+				"<title>My blog</title>",
+				`<a href="/posts/second/">Second</a>|2024-03-01|0</li><li><a href="/posts/first/">First</a>|2024-01-01|1</li>`,
+				fmt.Sprintf("2 posts, 1 tags, built %v at https://example.com/", time.Now().Year()),
+				`<p class="latest">Second</p>`,
+			},
+			"posts/first/index.html": {"<title>First - My blog</title>", `<p class="latest">Second</p>`},
+		} {
+			html, err := os.ReadFile(filepath.Join(out, fp))
+			if err != nil {
+				t.Fatalf("could not read %v: %v", fp, err)
+			}
+			if !noFormat { // compare without the line breaks and indentation added by the formatter
+				html = regexp.MustCompile(`>\s+`).ReplaceAll(html, []byte(">"))
+				html = regexp.MustCompile(`\s+<`).ReplaceAll(html, []byte("<"))
+			}
+			for _, e := range expected {
+				if !strings.Contains(string(html), e) {
+					t.Errorf("expected %v to contain %q, got %s", fp, e, html)
+				}
+			}
+			// {{.Content}} is the HTML of the posts
+			if parts := strings.Split(string(html), `<i class="posts"></i>`); len(parts) != 5 || !strings.Contains(parts[1], "blog-post") || parts[1] != parts[3] {
+				t.Errorf("expected {{.Content}} to match the HTML of the posts in %v, got %s", fp, html)
+			}
+		}
+	}
+}
+
+func TestBuildSourcePages(t *testing.T) {
+	src := t.TempDir()
+	out := t.TempDir()
+	blog := t.TempDir()
+
+	os.MkdirAll(filepath.Join(src, "about", "team"), 0755)
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`<div class="blog">{{.Content}}</div>`), 0644)
+	os.WriteFile(filepath.Join(src, "contact.html.tmpl"), []byte(`<p>{{.Site.Title}} contact</p>`), 0644)
+	os.WriteFile(filepath.Join(src, "about", "index.html.tmpl"), []byte(`<ul>{{range .Posts}}<li>{{.GetTitle}}</li>{{end}}</ul>`), 0644)
+	os.WriteFile(filepath.Join(src, "about", "team", "index.html.tmpl"), []byte(`<p>{{.Site.PostCount}} posts</p>`), 0644)
+	os.WriteFile(filepath.Join(blog, "post.md"), []byte("## Hello\nhey"), 0644)
+
+	if err := build(context.Background(), src, blog, out, buildOptions{Title: "My blog", NoFormat: true}); err != nil {
+		t.Fatal("failed to build html:", err)
+	}
+	for fp, expected := range map[string]string{
+		"index.html":            `<div class="blog">`,
+		"contact.html":          "<p>My blog contact</p>",
+		"about/index.html":      "<ul><li>Hello</li></ul>",
+		"about/team/index.html": "<p>1 posts</p>",
+	} {
+		html, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(fp)))
+		if err != nil {
+			t.Errorf("could not read %v: %v", fp, err)
+			continue
+		}
+		if !strings.HasPrefix(string(html), expected) {
+			t.Errorf("expected %v to start with %q, got %s", fp, expected, html)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "about", "index.html.tmpl")); err == nil {
+		t.Error("expected templates not to be copied")
+	}
+
+	// without index.html.tmpl, the template of index.html is ambiguous
+	os.Remove(filepath.Join(src, "index.html.tmpl"))
+	os.WriteFile(filepath.Join(src, "blog.html.tmpl"), []byte(`{{.Content}}`), 0644)
+	if err := build(context.Background(), src, blog, out, buildOptions{Force: true}); err == nil || !strings.Contains(err.Error(), "expected index.html.tmpl or exactly 1 template file") {
+		t.Error("expected error for ambiguous page templates, got", err)
+	}
+}
+
+func TestBuildLayouts(t *testing.T) {
+	src := t.TempDir()
+	out := t.TempDir()
+	blog := t.TempDir()
+
+	os.MkdirAll(filepath.Join(src, "_layouts"), 0755)
+	os.MkdirAll(filepath.Join(src, "_partials"), 0755)
+	os.MkdirAll(filepath.Join(src, "about"), 0755)
+	os.WriteFile(filepath.Join(src, "_layouts", "base.html.tmpl"), []byte(`<html><head><title>{{block "title" .}}{{.Site.Title}}{{end}}</title></head><body>{{template "header.tmpl" .}}{{block "content" .}}{{end}}</body></html>`), 0644)
+	os.WriteFile(filepath.Join(src, "_partials", "header.tmpl"), []byte(`<header>{{.Site.Title}}</header>`), 0644)
+	os.WriteFile(filepath.Join(src, "_partials", "byline.tmpl"), []byte(`<span class="byline">{{.DtPosted}}</span>`), 0644)
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`{{define "content"}}<main>{{.Content}}</main>{{end}}{{template "base.html.tmpl" .}}`), 0644)
+	os.WriteFile(filepath.Join(src, "about", "index.html.tmpl"), []byte(`{{define "title"}}About{{end}}{{define "content"}}<p>about</p>{{end}}{{template "base.html.tmpl" .}}`), 0644)
+	postTemplate := filepath.Join(t.TempDir(), "post.tmpl")
+	os.WriteFile(postTemplate, []byte(`<div class="post">{{template "byline.tmpl" .}}{{.Content}}</div>`), 0644)
+	os.WriteFile(filepath.Join(blog, "post.md"), []byte("---\ndate: 2024-01-01\n---\n## Hello\nhey"), 0644)
+
+	if err := build(context.Background(), src, blog, out, buildOptions{Title: "My blog", PostTemplateFile: postTemplate, NoFormat: true}); err != nil {
+		t.Fatal("failed to build html:", err)
+	}
+	for fp, expected := range map[string]string{
+		"index.html":       "<html><head><title>My blog</title></head><body><header>My blog</header><main><div class=\"post\"><span class=\"byline\">2024-01-01</span>\n<p>hey</p>\n</div></main></body></html>",
+		"about/index.html": "<html><head><title>About</title></head><body><header>My blog</header><p>about</p></body></html>",
+	} {
+		html, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(fp)))
+		if err != nil {
+			t.Errorf("could not read %v: %v", fp, err)
+			continue
+		}
+		if string(html) != expected {
+			t.Errorf("expected %v to be %q, got %q", fp, expected, html)
+		}
+	}
+	for _, fp := range []string{"_layouts", "_partials", "base.html"} {
+		if _, err := os.Stat(filepath.Join(out, fp)); err == nil {
+			t.Errorf("expected no %v in the output directory", fp)
+		}
+	}
+}
+
+func TestBuildTemplateFuncs(t *testing.T) {
+	src := t.TempDir()
+	out := t.TempDir()
+	blog := t.TempDir()
+
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`{{range sortBy .Posts "Published"}}<a href="{{absURL .GetURL}}">{{truncate 3 .GetTitle}}</a>{{end}}|{{len (first 1 .Posts)}}|{{.Content}}`), 0644)
+	postTemplate := filepath.Join(t.TempDir(), "post.tmpl")
+	os.WriteFile(postTemplate, []byte(`<a href="{{absURL .GetURL}}">{{.Published | date "2006"}}</a>`), 0644)
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("---\ndate: 2024-01-01\nslug: a\n---\n## Newer\nhey"), 0644)
+	os.WriteFile(filepath.Join(blog, "b.md"), []byte("---\ndate: 2023-01-01\nslug: b\n---\n## Older\nhi"), 0644)
+
+	if err := build(context.Background(), src, blog, out, buildOptions{BaseURL: "https://example.org", PostTemplateFile: postTemplate, NoFormat: true}); err != nil {
+		t.Fatal("failed to build html:", err)
+	}
+	html, err := os.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil {
+		t.Fatal("could not read index.html:", err)
+	}
+	for _, expected := range []string{
+		`<a href="https://example.org/posts/b/">Old…</a><a href="https://example.org/posts/a/">New…</a>|1|`, // page template
+		`<a href="https://example.org/posts/a/">2024</a>`,                                                   // post template
+	} {
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("expected index.html to contain %q, got %q", expected, html)
+		}
+	}
+}
+
+func TestBuildEscaping(t *testing.T) {
+	src := t.TempDir()
+	out := t.TempDir()
+	blog := t.TempDir()
+
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`<title>{{.Site.Title}}</title><main>{{.Content}}</main>{{range .Posts}}<a href="{{index .Tags 0}}" title="{{index .Tags 1}}">{{.HTML}}</a>{{end}}<script>var title = {{.Site.Title}};</script>`), 0644)
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("---\ntags: ['javascript:alert(1)', '\"><script>alert(1)</script>']\n---\n## Title\nhey <b>there</b>"), 0644)
+
+	if err := build(context.Background(), src, blog, out, buildOptions{Title: "</title><script>alert(1)</script>", NoFormat: true}); err != nil {
+		t.Fatal("failed to build html:", err)
+	}
+	html, err := os.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil {
+		t.Fatal("could not read index.html:", err)
+	}
+	html = regexp.MustCompile(`>\s+<`).ReplaceAll(html, []byte("><"))
+	for _, expected := range []string{
+		"<title>&lt;/title&gt;&lt;script&gt;alert(1)&lt;/script&gt;</title>",
+		"<main><div class=\"blog-post\">", // the posts are inserted as HTML
+		"<p>hey <b>there</b></p>",
+		`<a href="#ZgotmplZ" title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;"><div class="blog-post">`,
+		`var title = "\u003c/title\u003e\u003cscript\u003ealert(1)\u003c/script\u003e";`,
+	} {
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("expected index.html to contain %q, got %s", expected, html)
+		}
+	}
+	if strings.Contains(string(html), "<script>alert(1)") {
+		t.Errorf("expected no injected script, got %s", html)
+	}
+
+	// the tag cloud is inserted as HTML with the names of the tags escaped
+	html, err = os.ReadFile(filepath.Join(out, "tags", "index.html"))
+	if err != nil {
+		t.Fatal("could not read tag cloud:", err)
+	}
+	if !strings.Contains(string(html), `<ul class="tag-cloud tags">`) || strings.Contains(string(html), "<script>alert(1)") {
+		t.Errorf("expected escaped tag cloud, got %s", html)
+	}
+}
+
+func TestBuildContentInWithAndRange(t *testing.T) {
+	src := t.TempDir()
+	blog := t.TempDir()
+
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`{{$content := .Content}}{{with .Site}}<h1>{{.Title}}</h1><main>{{$content}}</main>{{end}}{{range .Pagination.Pages}}<section>{{$.Content}}</section>{{end}}{{with .Content}}<aside>{{.}}</aside>{{end}}`), 0644)
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("## Title\nhey <b>there</b>"), 0644)
+
+	for _, noFormat := range []bool{false, true} {
+		out := t.TempDir()
+		if err := build(context.Background(), src, blog, out, buildOptions{Title: "<Blog>", NoFormat: noFormat}); err != nil {
+			t.Fatal("failed to build html:", err)
+		}
+		html, err := os.ReadFile(filepath.Join(out, "index.html"))
+		if err != nil {
+			t.Fatal("could not read index.html:", err)
+		}
+		html = regexp.MustCompile(`>\s+<`).ReplaceAll(html, []byte("><"))
+		// the content is inserted as HTML within {{with}} and {{range}}, other values are still escaped
+		for _, expected := range []string{"&lt;Blog&gt;", `<main><div class="blog-post">`, `<section><div class="blog-post">`, `<aside><div class="blog-post">`} {
+			if !strings.Contains(string(html), expected) {
+				t.Errorf("expected index.html to contain %q, got %s", expected, html)
+			}
+		}
+		if strings.Count(string(html), `<div class="blog-post">`) != 3 {
+			t.Errorf("expected the posts to be inserted three times, got %s", html)
+		}
+	}
+}
+
+func TestBuildDotPrintsContent(t *testing.T) {
+	src := t.TempDir()
+	blog := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "_layouts"), 0755)
+	os.WriteFile(filepath.Join(src, "_layouts", "base.html.tmpl"), []byte(`<main>{{block "content" .}}{{end}}</main>`), 0644)
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte(`<div>{{.}}</div>`), 0644)
+	os.WriteFile(filepath.Join(src, "about.html.tmpl"), []byte(`{{define "content"}}{{.}}{{end}}{{template "base.html.tmpl" .}}<p>{{.String}}</p>`), 0644)
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("---\ntags: [go]\n---\n## Title\nhey <b>there</b>"), 0644)
+
+	// {{.}} of the page inserts the posts as HTML, whether they are streamed into the page or not
+	for _, noFormat := range []bool{false, true} {
+		out := t.TempDir()
+		if err := build(context.Background(), src, blog, out, buildOptions{NoFormat: noFormat}); err != nil {
+			t.Fatal("failed to build html:", err)
+		}
+		for _, fp := range []string{"index.html", "about.html", filepath.Join("tags", "go", "index.html")} {
+			html, err := os.ReadFile(filepath.Join(out, fp))
+			if err != nil {
+				t.Fatal(err)
+			}
+			posts := 1
+			if fp == "about.html" {
+				posts = 2
+			}
+			if count := strings.Count(string(html), `<div class="blog-post">`); count != posts || strings.Contains(string(html), "&lt;") {
+				t.Errorf("expected %v posts inserted as HTML in %v, got %s", posts, fp, html)
+			}
+		}
+	}
+}
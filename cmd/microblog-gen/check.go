@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	microblog "github.com/felix-schott/microblog-gen/pkg"
+)
+
+type checkOptions struct {
+	PostTemplateFile string
+	Sections         bool
+}
+
+// Checks the posts in blogDirectory and the page templates (*.html.tmpl) in sourceDirectory without building the
+// website. Returns all problems joined with errors.Join, or nil if there are none.
+func check(sourceDirectory string, blogDirectory string, options checkOptions) error {
+	blogOptions := []microblog.Option{microblog.WithPartials(os.DirFS(sourceDirectory), microblog.PartialPatterns...)}
+	if options.PostTemplateFile != "" {
+		blogOptions = append(blogOptions, microblog.WithTemplateFile(options.PostTemplateFile))
+	}
+	if options.Sections {
+		blogOptions = append(blogOptions, microblog.WithSections())
+	}
+	var problems []error
+	if err := microblog.Check(blogDirectory, blogOptions...); err != nil {
+		problems = append(problems, err)
+	}
+	if err := microblog.CheckPageTemplates(os.DirFS(sourceDirectory), microblog.SiteOptions{}); err != nil {
+		problems = append(problems, err)
+	}
+	return errors.Join(problems...)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	src := t.TempDir()
+	blog := t.TempDir()
+
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte("<html><body>{{.Nope}}</body></html>"), 0644)
+	os.WriteFile(filepath.Join(blog, "a.md"), []byte("## Title\nhey\n\n## Another title\nhi"), 0644)
+	os.WriteFile(filepath.Join(blog, "b.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(blog, "c.md"), []byte("\nhey"), 0644)
+
+	err := check(src, blog, checkOptions{})
+	if err == nil {
+		t.Fatal("expected problems")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	expected := []string{
+		filepath.Join(blog, "a.md") + ":4:1: more than one heading in blog post",
+		filepath.Join(blog, "c.md") + ":2:1: no heading found",
+	}
+	for _, e := range expected {
+		if !slices.Contains(lines, e) {
+			t.Errorf("expected %q in %q", e, lines)
+		}
+	}
+	if len(lines) != 3 || !strings.Contains(lines[2], "can't evaluate field Nope") {
+		t.Errorf("expected page template error, got %q", lines)
+	}
+
+	os.Remove(filepath.Join(blog, "a.md"))
+	os.Remove(filepath.Join(blog, "c.md"))
+	os.WriteFile(filepath.Join(src, "index.html.tmpl"), []byte("<html><body>{{.}}</body></html>"), 0644)
+	if err := check(src, blog, checkOptions{}); err != nil {
+		t.Errorf("expected no problems, got %v", err)
+	}
+}
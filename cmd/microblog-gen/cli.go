@@ -1,23 +1,166 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+
+	microblog "github.com/felix-schott/microblog-gen/pkg"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "reorder":
+			runReorder(os.Args[2:])
+			return
+		case "check":
+			runCheck(os.Args[2:])
+			return
+		case "build": // default command
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		}
+	}
+
 	sourceDirectory := flag.String("i", "./src", "Source directory with HTML template and other assets.")
 	blogDirectory := flag.String("b", "./blog", "Directory that contains blog posts as Markdown files.")
 	outputDirectory := flag.String("o", "./build", "Output directory for generated files.")
+	archive := flag.Bool("archive", false, "Generate archive pages grouped by year and month (implies -permalinks).")
+	noCache := flag.Bool("no-cache", false, "Render all posts instead of reusing the HTML of unchanged posts from the last build.")
+	noFormat := flag.Bool("no-format", false, "Write the generated HTML without formatting it. Streams the posts into index.html unless -p is set.")
 	force := flag.Bool("f", false, "Overwrite output directory contents.")
+	permalinks := flag.Bool("permalinks", false, "Generate a page for every post at posts/<slug>/index.html.")
+	expired := flag.String("expired", "archive", "Permalink pages of expired posts, generated even without -permalinks: archive (keep them with a notice) or remove.")
+	drafts := flag.Bool("drafts", false, "Include drafts and posts scheduled for a future date, marked with a banner.")
 	templateFile := flag.String("t", "", "Path to a HTML template for generated blog posts")
+	sortOrder := flag.String("s", string(microblog.SortByDateDescending), "Order of blog posts: name, natural, date-asc, date-desc, weight or manifest.")
+	pageSize := flag.Int("p", 0, "Number of posts per index page (index.html, page/2/index.html, ...), 0 disables pagination.")
+	related := flag.Int("related", 0, "Number of related posts available as {{.Related}} in the post template.")
+	workers := flag.Int("j", 0, "Number of posts rendered in parallel, 0 uses the number of CPUs.")
+	title := flag.String("title", "", "Title of the website, available as {{.Site.Title}} in the page template.")
+	baseURL := flag.String("base-url", "", "URL the website is served from, available as {{.Site.BaseURL}} in the page template.")
+	sections := flag.Bool("r", false, "Scan subdirectories of the blog directory, every subdirectory becomes a section with its own listing.")
 
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %v [build|check|reorder]:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	if err := build(*sourceDirectory, *blogDirectory, *outputDirectory, buildOptions{
+	order, err := microblog.ParseSortOrder(*sortOrder)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *expired != "archive" && *expired != "remove" {
+		log.Fatalf("unknown value %q for -expired, must be archive or remove", *expired)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := build(ctx, *sourceDirectory, *blogDirectory, *outputDirectory, buildOptions{
 		Force:            *force,
 		PostTemplateFile: *templateFile,
+		SortOrder:        order,
+		Sections:         *sections,
+		Drafts:           *drafts,
+		RelatedPosts:     *related,
+		PageSize:         *pageSize,
+		Permalinks:       *permalinks,
+		RemoveExpired:    *expired == "remove",
+		Archive:          *archive,
+		Workers:          *workers,
+		NoCache:          *noCache,
+		NoFormat:         *noFormat,
+		Title:            *title,
+		BaseURL:          *baseURL,
 	}); err != nil {
+		exitWithError(err)
+	}
+}
+
+// Prints err and exits. Problems with posts are printed one per line as file:line:column: message, so editors
+// can jump to them.
+func exitWithError(err error) {
+	if leaves := errorLeaves(err); len(leaves) > 1 || len(collectPostErrors(err)) > 0 {
+		for _, e := range leaves {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		os.Exit(1)
+	}
+	log.Fatal(err)
+}
+
+// Returns the errors joined in err with errors.Join, one per problem: a *microblog.PostError as is, which gives the
+// position of the problem, and any other error with the context it has been wrapped in.
+func errorLeaves(err error) []error {
+	switch e := err.(type) {
+	case *microblog.PostError:
+		return []error{e}
+	case interface{ Unwrap() []error }:
+		var leaves []error
+		for _, inner := range e.Unwrap() {
+			leaves = append(leaves, errorLeaves(inner)...)
+		}
+		return leaves
+	case interface{ Unwrap() error }:
+		var postErr *microblog.PostError
+		if inner := errorLeaves(e.Unwrap()); len(inner) > 1 || errors.As(err, &postErr) {
+			return inner
+		}
+	}
+	return []error{err}
+}
+
+// Returns all *microblog.PostError wrapped by err, including those joined with errors.Join.
+func collectPostErrors(err error) []*microblog.PostError {
+	var postErrors []*microblog.PostError
+	for _, e := range errorLeaves(err) {
+		if postErr, ok := e.(*microblog.PostError); ok {
+			postErrors = append(postErrors, postErr)
+		}
+	}
+	return postErrors
+}
+
+func runCheck(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	sourceDirectory := flags.String("i", "./src", "Source directory with HTML template and other assets.")
+	blogDirectory := flags.String("b", "./blog", "Directory that contains blog posts as Markdown files.")
+	templateFile := flags.String("t", "", "Path to a HTML template for generated blog posts")
+	sections := flags.Bool("r", false, "Scan subdirectories of the blog directory.")
+
+	flags.Parse(args)
+
+	// one problem per line, see exitWithError
+	if err := check(*sourceDirectory, *blogDirectory, checkOptions{
+		PostTemplateFile: *templateFile,
+		Sections:         *sections,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runReorder(args []string) {
+	flags := flag.NewFlagSet("reorder", flag.ExitOnError)
+	blogDirectory := flags.String("b", "./blog", "Directory that contains blog posts as Markdown files.")
+	dryRun := flags.Bool("n", false, "Print the renames without applying them.")
+	move := flags.String("move", "", "File name of a post to move to the position given by -to.")
+	insert := flags.String("insert", "", "File name of a new post (without numeric prefix) to insert at the position given by -to.")
+	position := flags.Int("to", 1, "Target position (starting at 1) for -move and -insert.")
+
+	flags.Parse(args)
+
+	if err := reorder(*blogDirectory, reorderOptions{
+		DryRun:   *dryRun,
+		Move:     *move,
+		Insert:   *insert,
+		Position: *position,
+	}, os.Stdout); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	microblog "github.com/felix-schott/microblog-gen/pkg"
+)
+
+type reorderOptions struct {
+	DryRun   bool
+	Move     string
+	Insert   string
+	Position int
+}
+
+// Renumbers the prefixes of the Markdown files in blogDirectory and prints the renamed files to w.
+func reorder(blogDirectory string, options reorderOptions, w io.Writer) error {
+	var operations []microblog.ReorderOperation
+	switch {
+	case options.Move != "" && options.Insert != "":
+		return errors.New("only one of move and insert can be used at a time")
+	case options.Move != "":
+		operations = append(operations, microblog.MovePost(options.Move, options.Position))
+	case options.Insert != "":
+		operations = append(operations, microblog.InsertPost(options.Insert, options.Position))
+	}
+	renames, err := microblog.Reorder(blogDirectory, options.DryRun, operations...)
+	if err != nil {
+		return fmt.Errorf("could not reorder posts in %v: %v", blogDirectory, err)
+	}
+	if len(renames) == 0 {
+		fmt.Fprintln(w, "nothing to rename")
+	}
+	for _, r := range renames {
+		fmt.Fprintf(w, "%v -> %v\n", r.From, r.To)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReorder(t *testing.T) {
+	blog := t.TempDir()
+
+	os.WriteFile(filepath.Join(blog, "1_first.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(blog, "second.md"), []byte("## Title\nhey"), 0644)
+
+	var out bytes.Buffer
+	if err := reorder(blog, reorderOptions{Insert: "second.md", Position: 1}, &out); err != nil {
+		t.Error("failed to reorder posts:", err)
+		t.FailNow()
+	}
+
+	if !strings.Contains(out.String(), "second.md -> 001_second.md") || !strings.Contains(out.String(), "1_first.md -> 002_first.md") {
+		t.Error("expected output to list renamed files, got", out.String())
+	}
+	for _, name := range []string{"001_second.md", "002_first.md"} {
+		if _, err := os.Stat(filepath.Join(blog, name)); err != nil {
+			t.Errorf("expected %v to exist: %v", name, err)
+		}
+	}
+
+	if err := reorder(blog, reorderOptions{Insert: "a.md", Move: "b.md"}, &out); err == nil {
+		t.Error("expected error when using move and insert at the same time")
+	}
+}
@@ -0,0 +1,150 @@
+package microblog
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A post together with its publication date, see Blog.Archive.
+type ArchiveEntry struct {
+	BlogPost
+	Date time.Time
+}
+
+// Posts published in the same month.
+type ArchiveMonth struct {
+	Year    int
+	Month   time.Month
+	Entries []ArchiveEntry // newest first
+}
+
+// Posts published in the same year.
+type ArchiveYear struct {
+	Year   int
+	Months []ArchiveMonth // newest first
+}
+
+// Returns the URL of the archive page of the year, e.g. /archive/2024/.
+func (y ArchiveYear) URL() string {
+	return fmt.Sprintf("/archive/%v/", y.Year)
+}
+
+// Returns the number of posts published in the year.
+func (y ArchiveYear) Count() int {
+	count := 0
+	for _, m := range y.Months {
+		count += len(m.Entries)
+	}
+	return count
+}
+
+// Groups the posts of the blog by year and month of their publication date (see BlogPost.GetPublicationDate),
+// newest first. Posts that haven't been published yet are listed under the current date. Posts published on the
+// same day keep the order of the blog.
+func (b *blog) Archive() ([]ArchiveYear, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	entries := make([]ArchiveEntry, 0, len(b.Posts))
+	for _, p := range b.Posts {
+		dt, err := p.GetPublicationDate()
+		if err != nil {
+			return nil, fmt.Errorf("could not determine publication date of %v: %v", p.GetFilePath(), err)
+		}
+		if dt == nil {
+			dt = &today
+		}
+		entries = append(entries, ArchiveEntry{BlogPost: p, Date: *dt})
+	}
+	slices.SortStableFunc(entries, func(a, b ArchiveEntry) int {
+		return cmp.Compare(b.Date.Format(time.DateOnly), a.Date.Format(time.DateOnly))
+	})
+
+	var years []ArchiveYear
+	for _, e := range entries {
+		if len(years) == 0 || years[len(years)-1].Year != e.Date.Year() {
+			years = append(years, ArchiveYear{Year: e.Date.Year()})
+		}
+		y := &years[len(years)-1]
+		if len(y.Months) == 0 || y.Months[len(y.Months)-1].Month != e.Date.Month() {
+			y.Months = append(y.Months, ArchiveMonth{Year: y.Year, Month: e.Date.Month()})
+		}
+		m := &y.Months[len(y.Months)-1]
+		m.Entries = append(m.Entries, e)
+	}
+	return years, nil
+}
+
+// Optional template in the source directory for the archive pages. It is executed with an archivePage.
+const archiveTemplateFile = "archive.tmpl"
+
+// Data passed to the archive template.
+type archivePage struct {
+	Years []ArchiveYear // all years, newest first
+	Year  *ArchiveYear  // year of a per-year page, nil on the overview page
+}
+
+// Renders the given years as nested lists, used if the source directory doesn't contain an archive template.
+func renderArchive(years []ArchiveYear) template.HTML {
+	var s strings.Builder
+	s.WriteString(`<div class="archive">`)
+	for _, y := range years {
+		fmt.Fprintf(&s, `<section class="archive-year"><h2><a href="%v">%v</a></h2>`, y.URL(), y.Year)
+		for _, m := range y.Months {
+			fmt.Fprintf(&s, `<h3>%v</h3><ul>`, m.Month)
+			for _, e := range m.Entries {
+				fmt.Fprintf(&s, `<li><span class="dt-posted">%v</span> <a href="%v">%v</a></li>`,
+					e.Date.Format(time.DateOnly), html.EscapeString(e.GetURL()), html.EscapeString(e.GetTitle()))
+			}
+			s.WriteString("</ul>")
+		}
+		s.WriteString("</section>")
+	}
+	s.WriteString("</div>")
+	return template.HTML(s.String())
+}
+
+// Writes the archive overview (archive/index.html) and a page per year (e.g. archive/2024/index.html). The pages
+// are rendered with archive.tmpl from the source file system if it exists, otherwise the archive is inserted into
+// the page template.
+func writeArchivePages(tmpl *pageTemplate, source fs.FS, outputDirectory string, b Blog) error {
+	years, err := b.Archive()
+	if err != nil {
+		return fmt.Errorf("could not build archive: %v", err)
+	}
+
+	var archiveTmpl *template.Template
+	if _, err := fs.Stat(source, archiveTemplateFile); err == nil {
+		if archiveTmpl, err = parsePageTemplate(source, archiveTemplateFile, archiveTemplateFile, tmpl.funcs); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not read file %v: %v", archiveTemplateFile, err)
+	}
+
+	write := func(outputFp string, data archivePage) error {
+		if archiveTmpl != nil {
+			return writePage(&pageTemplate{Template: archiveTmpl, format: tmpl.format, site: tmpl.site, funcs: tmpl.funcs}, outputFp, data)
+		}
+		if data.Year != nil {
+			return writePage(tmpl, outputFp, tmpl.singlePage(renderArchive([]ArchiveYear{*data.Year})))
+		}
+		return writePage(tmpl, outputFp, tmpl.singlePage(renderArchive(data.Years)))
+	}
+	if err := write(filepath.Join(outputDirectory, "archive", "index.html"), archivePage{Years: years}); err != nil {
+		return err
+	}
+	for i := range years {
+		outputFp := filepath.Join(outputDirectory, "archive", fmt.Sprint(years[i].Year), "index.html")
+		if err := write(outputFp, archivePage{Years: years, Year: &years[i]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
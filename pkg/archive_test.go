@@ -0,0 +1,83 @@
+package microblog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBlogArchive(t *testing.T) {
+	d := t.TempDir()
+
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("---\ndate: 2023-12-24\n---\n## A\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("---\ndate: 2024-01-05\n---\n## B\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "c.md"), []byte("---\ndate: 2024-01-20\n---\n## C\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "d.md"), []byte("---\ndate: 2024-03-01\n---\n## D\nhey"), 0644)
+
+	// tracked publication dates are used for posts without date in the front matter
+	os.WriteFile(filepath.Join(d, "e.md"), []byte("## E\nhey"), 0644)
+	registry, err := (&sqlitePool).Acquire(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, err := registry.DB.Exec("INSERT INTO posts VALUES ('e.md', '2024-03-02');"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	blog, err := NewBlog(d, WithPublicationTracking())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	years, err := blog.Archive()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	type month struct {
+		year  int
+		month time.Month
+		names []string
+	}
+	var months []month
+	for _, y := range years {
+		for _, m := range y.Months {
+			var names []string
+			for _, e := range m.Entries {
+				names = append(names, e.GetName())
+			}
+			months = append(months, month{y.Year, m.Month, names})
+		}
+	}
+	expected := []month{
+		{2024, time.March, []string{"e.md", "d.md"}},
+		{2024, time.January, []string{"c.md", "b.md"}},
+		{2023, time.December, []string{"a.md"}},
+	}
+	if len(months) != len(expected) {
+		t.Errorf("expected archive %v, got %v", expected, months)
+		t.FailNow()
+	}
+	for i := range expected {
+		if months[i].year != expected[i].year || months[i].month != expected[i].month || len(months[i].names) != len(expected[i].names) {
+			t.Errorf("expected %v, got %v", expected[i], months[i])
+			continue
+		}
+		for j := range expected[i].names {
+			if months[i].names[j] != expected[i].names[j] {
+				t.Errorf("expected %v, got %v", expected[i], months[i])
+			}
+		}
+	}
+
+	if years[0].Count() != 4 || years[0].URL() != "/archive/2024/" {
+		t.Errorf("expected 4 posts in 2024 at /archive/2024/, got %v at %v", years[0].Count(), years[0].URL())
+	}
+	if e := years[0].Months[0].Entries[0]; !e.Date.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected tracked publication date 2024-03-02, got %v", e.Date)
+	}
+}
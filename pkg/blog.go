@@ -2,10 +2,17 @@ package microblog
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -15,51 +22,224 @@ import (
 type Blog interface {
 	RenderPosts() ([]byte, error)
 	RenderPostsAsync() ([]byte, error)
+	RenderPostsContext(ctx context.Context, opts RenderOptions) ([]byte, error)
+	WriteHtml(w io.Writer) error
 	GetDirectory() string
 	GetBlogPosts() []BlogPost
+	Archived() []BlogPost
+	Series() []*Series
+	Archive() ([]ArchiveYear, error)
+	Sections() []string
+	Section(name string) Blog
+	Tags() []Term
+	PostsByTag(tag string) []BlogPost
+	Categories() []Term
+	PostsByCategory(category string) []BlogPost
 }
 
 type blog struct {
-	Posts     []BlogPost
-	Directory string
+	Posts        []BlogPost
+	archived     []BlogPost
+	Directory    string
+	join         func(elem ...string) string // joins Directory and paths of posts
+	sortOrder    SortOrder
+	manifest     []string
+	manifestFile string
+	recursive    bool
+	drafts       bool
+	related      int
+	postOptions  []PostOption
+}
+
+// Returns all tags used by the posts of the blog, sorted by slug, with the number of posts per tag.
+func (b *blog) Tags() []Term {
+	return collectTerms(b.Posts, BlogPost.GetTags)
+}
+
+// Returns the posts tagged with tag, in the same order as the blog. Tags are compared by their slug, i.e.
+// "Go" and "go" are the same tag.
+func (b *blog) PostsByTag(tag string) []BlogPost {
+	return filterByTerm(b.Posts, tag, BlogPost.GetTags)
+}
+
+// Returns all categories used by the posts of the blog, sorted by slug, with the number of posts per category.
+func (b *blog) Categories() []Term {
+	return collectTerms(b.Posts, BlogPost.GetCategories)
+}
+
+// Returns the posts in category, in the same order as the blog. Categories are compared by their slug.
+func (b *blog) PostsByCategory(category string) []BlogPost {
+	return filterByTerm(b.Posts, category, BlogPost.GetCategories)
+}
+
+// Configures a Blog created with NewBlog. Both BlogOption and PostOption satisfy this interface, which means
+// post options passed to NewBlog are applied to every BlogPost in the directory.
+type Option interface {
+	applyToBlog(*blog) error
+}
+
+// Configures a Blog.
+type BlogOption func(*blog) error
+
+func (o BlogOption) applyToBlog(b *blog) error {
+	return o(b)
+}
+
+// Set the order of the posts returned by Blog.GetBlogPosts and rendered by Blog.RenderPosts.
+// Defaults to SortByName, i.e. the lexical order of the file names.
+func WithSortOrder(order SortOrder) BlogOption {
+	return func(b *blog) error {
+		if _, err := ParseSortOrder(string(order)); err != nil {
+			return err
+		}
+		b.sortOrder = order
+		return nil
+	}
+}
+
+// Scan subdirectories of the blog directory for posts as well. Every subdirectory becomes a section
+// (see BlogPost.GetSection and Blog.Section). Directories starting with a dot are skipped. The top-level subdirectories
+// must not be named posts, page, tags, categories, series or archive, which hold the pages generated by BuildSite.
+func WithSections() BlogOption {
+	return func(b *blog) error {
+		b.recursive = true
+		return nil
+	}
+}
+
+// Include drafts and posts scheduled for a future date (see Metadata.Draft and Metadata.PublishAt), which are
+// excluded by default. Neither of them is assigned a publication date by publication tracking.
+func WithDrafts() BlogOption {
+	return func(b *blog) error {
+		b.drafts = true
+		return nil
+	}
+}
+
+// Order the posts explicitly. The manifest lists the names of all posts (see BlogPost.GetName) in display order.
+// Implies WithSortOrder(SortByManifest) and takes precedence over a manifest file in the blog directory.
+func WithManifest(names ...string) BlogOption {
+	return func(b *blog) error {
+		b.manifest = names
+		b.sortOrder = SortByManifest
+		return nil
+	}
 }
 
 // Render all posts as HTML and return them as a single byte slice.
 func (b *blog) RenderPosts() ([]byte, error) {
 	var htmlBuffer bytes.Buffer
+	if err := b.WriteHtml(&htmlBuffer); err != nil {
+		return []byte{}, err
+	}
+	return htmlBuffer.Bytes(), nil
+}
+
+// Render all posts as HTML and write them to w one after the other, in the same order as RenderPosts. Unlike
+// RenderPosts, only the HTML of one post is held in memory at a time. If a post fails to render, the posts before
+// it have already been written to w.
+func (b *blog) WriteHtml(w io.Writer) error {
 	for _, post := range b.Posts {
-		err := post.WriteHtml(&htmlBuffer)
-		if err != nil {
-			return []byte{}, fmt.Errorf("could not render html for post %v: %v", post.GetFilePath(), err)
+		if err := post.WriteHtml(w); err != nil {
+			return fmt.Errorf("could not render html for post %v: %w", post.GetFilePath(), err)
 		}
 	}
-	return htmlBuffer.Bytes(), nil
+	return nil
 }
 
-// Alternative implementation of RenderPosts using goroutines.
+// Alternative implementation of RenderPosts using goroutines, see RenderPostsContext.
 func (b *blog) RenderPostsAsync() ([]byte, error) {
-	g := new(errgroup.Group)
+	return b.RenderPostsContext(context.Background(), RenderOptions{})
+}
 
-	var bufSlice = make([][]byte, len(b.Posts))
-	for i, p := range b.Posts {
+// Options for Blog.RenderPostsContext and RenderEach.
+type RenderOptions struct {
+	// Maximum number of posts rendered at the same time. Defaults to runtime.GOMAXPROCS(0) if not positive.
+	Workers int
+}
+
+// Render all posts as HTML concurrently and return them as a single byte slice, in the same order as RenderPosts.
+// See RenderEach for the handling of errors and cancellation.
+func (b *blog) RenderPostsContext(ctx context.Context, opts RenderOptions) ([]byte, error) {
+	rendered, err := RenderEach(ctx, b.Posts, opts)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Join(rendered, nil), nil
+}
+
+// Renders every post as HTML, using up to opts.Workers goroutines, and returns the HTML of each post in the order
+// of posts. All posts are rendered even if some of them fail, the returned error then joins the errors of all
+// failed posts (see errors.Join). Once ctx is done, no further posts are rendered and the error includes ctx.Err().
+func RenderEach(ctx context.Context, posts []BlogPost, opts RenderOptions) ([][]byte, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	rendered := make([][]byte, len(posts))
+	errs := make([]error, len(posts)) // indexed like posts, so the joined error doesn't depend on scheduling
+	var g errgroup.Group
+	g.SetLimit(workers)
+	for i, p := range posts {
+		if ctx.Err() != nil {
+			break
+		}
 		g.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
 			var buf bytes.Buffer
 			if err := p.WriteHtml(&buf); err != nil {
-				return err
+				errs[i] = fmt.Errorf("could not render html for post %v: %w", p.GetFilePath(), err)
+				return nil
 			}
-			bufSlice[i] = buf.Bytes()
+			rendered[i] = buf.Bytes()
 			return nil
 		})
 	}
-	if err := g.Wait(); err != nil {
-		return []byte{}, nil
+	g.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Join(append([]error{err}, errs...)...)
 	}
-	// collect buffers
-	var buf bytes.Buffer
-	for _, b := range bufSlice {
-		buf.Write(b)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
-	return buf.Bytes(), nil
+	return rendered, nil
+}
+
+// Renders the posts like RenderEach and writes their HTML to w in the order of posts. The posts are rendered in
+// windows of opts.Workers posts, so only the HTML of one window is held in memory at a time. Like RenderEach, all
+// posts are rendered even if some of them fail, but nothing is written to w after the first failure. The returned
+// error joins the errors of all failed posts, or includes ctx.Err() once ctx is done.
+func writeEach(ctx context.Context, w io.Writer, posts []BlogPost, opts RenderOptions) error {
+	window := opts.Workers
+	if window <= 0 {
+		window = runtime.GOMAXPROCS(0)
+	}
+	var errs []error
+	for start := 0; start < len(posts); start += window {
+		rendered, err := RenderEach(ctx, posts[start:min(start+window, len(posts))], opts)
+		if ctx.Err() != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if err != nil {
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				errs = append(errs, joined.Unwrap()...)
+			} else {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if len(errs) > 0 {
+			continue
+		}
+		for _, html := range rendered {
+			if _, err := w.Write(html); err != nil {
+				return err
+			}
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Returns the directory that contains all blog posts.
@@ -72,14 +252,66 @@ func (b *blog) GetBlogPosts() []BlogPost {
 	return b.Posts
 }
 
+// Returns the posts that have expired (see Metadata.Expires), in the same order as the blog. Expired posts
+// are not part of GetBlogPosts and are thus omitted from RenderPosts, the sections and the taxonomies.
+func (b *blog) Archived() []BlogPost {
+	return b.archived
+}
+
+// Returns all series of the blog, in the order in which they first appear in the blog.
+func (b *blog) Series() []*Series {
+	var series []*Series
+	for _, p := range b.Posts {
+		if s := p.Series(); s != nil && !slices.Contains(series, s) {
+			series = append(series, s)
+		}
+	}
+	return series
+}
+
+// Returns the names of all sections that contain posts (see BlogPost.GetSection), in the order in which they
+// first appear in the blog. Posts at the top level of the blog directory don't belong to a section.
+func (b *blog) Sections() []string {
+	var sections []string
+	for _, p := range b.Posts {
+		if s := p.GetSection(); s != "" && !slices.Contains(sections, s) {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// Returns a Blog that contains only the posts of the given section, in the same order.
+// The returned Blog is empty if there is no such section.
+func (b *blog) Section(name string) Blog {
+	section := &blog{Directory: b.join(b.Directory, name), join: b.join}
+	for _, p := range b.Posts {
+		if p.GetSection() == name {
+			section.Posts = append(section.Posts, p)
+		}
+	}
+	for _, p := range b.archived {
+		if p.GetSection() == name {
+			section.archived = append(section.archived, p)
+		}
+	}
+	return section
+}
+
 // Creates a new Blog.
 // The first parameter is the path to the directory that contains the different blog posts as .md files.
 // You can also pass post options to apply to all the BlogPost structs created within this function,
-// see microblog.BlogPost for more information.
+// see microblog.BlogPost for more information, and blog options such as WithSortOrder.
+// If the directory contains a manifest file (blog.yaml or order.txt, see ManifestYaml), the posts are
+// ordered as listed in the manifest, regardless of WithSortOrder. A *ManifestError is returned if the
+// manifest doesn't list exactly the .md files in the directory. The post template is parsed once for all
+// posts, so syntax errors in the template and references to fields that RenderedPost doesn't have are reported
+// by NewBlog rather than when rendering.
 //
 //	blog, err := microblog.NewBlog("/path/to/mm/directory")
 //	blog, err := microblog.NewBlog("/path/to/mm/directory", microblog.WithTemplateFile("/path/to/html/template"))
-func NewBlog(directory string, postOptions ...func(*blogPost) error) (Blog, error) {
+//	blog, err := microblog.NewBlog("/path/to/mm/directory", microblog.WithSortOrder(microblog.SortByDateDescending))
+func NewBlog(directory string, options ...Option) (Blog, error) {
 	i, err := os.Stat(directory)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -90,23 +322,146 @@ func NewBlog(directory string, postOptions ...func(*blogPost) error) (Blog, erro
 	if !i.IsDir() {
 		return nil, fmt.Errorf("%v must be a directory", directory)
 	}
-	markdownFiles, err := filepath.Glob(filepath.Join(directory, "*.md"))
+	options = append([]Option{WithRegistryDirectory(directory)}, options...)
+	return newBlog(os.DirFS(directory), &blog{Directory: directory, join: filepath.Join}, options...)
+}
+
+// Creates a new Blog from the directory dir of the file system fsys, e.g. an embed.FS or a zip.Reader.
+// dir is a slash-separated path as accepted by fs.Sub, use "." for the root of fsys. GetFilePath of the
+// posts returns their slash-separated path in fsys. Apart from that, NewBlogFS behaves like NewBlog, except
+// that publication tracking requires WithRegistryDirectory, as fsys may not be backed by a directory on disk.
+//
+//	//go:embed posts
+//	var posts embed.FS
+//
+//	blog, err := microblog.NewBlogFS(posts, "posts")
+func NewBlogFS(fsys fs.FS, dir string, options ...Option) (Blog, error) {
+	i, err := fs.Stat(fsys, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("directory %v does not exist", dir)
+		}
+		return nil, fmt.Errorf("could not acquire file info for %v: %v", dir, err)
+	}
+	if !i.IsDir() {
+		return nil, fmt.Errorf("%v must be a directory", dir)
+	}
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not open directory %v: %v", dir, err)
+	}
+	return newBlog(sub, &blog{Directory: dir, join: path.Join}, options...)
+}
+
+// Creates the posts of the blog b from fsys, which is rooted at the blog directory.
+func newBlog(fsys fs.FS, b *blog, options ...Option) (Blog, error) {
+	directory := b.Directory
+	for _, o := range options {
+		if err := o.applyToBlog(b); err != nil {
+			return nil, err
+		}
+	}
+	markdownFiles, err := findMarkdownFiles(fsys, b.recursive)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search for markdown files in %v: %v", directory, err)
 	}
 	if len(markdownFiles) == 0 {
 		return nil, errors.New("there must be at least .md file in the directory")
 	}
-	var blogPosts = make([]BlogPost, 0, len(markdownFiles))
+	if err := checkSections(markdownFiles); err != nil {
+		return nil, err
+	}
+	if b.manifest == nil {
+		fp, entries, err := readManifest(fsys, directory, b.join)
+		if err != nil {
+			return nil, err
+		}
+		if fp != "" {
+			b.manifest, b.manifestFile, b.sortOrder = entries, fp, SortByManifest
+			if b.manifest == nil {
+				b.manifest = []string{}
+			}
+		}
+	}
+	var posts = make([]*blogPost, 0, len(markdownFiles))
 	for _, md := range markdownFiles {
-		post, err := NewBlogPost(md, postOptions...)
+		fp := b.join(directory, md)
+		post, err := newBlogPost(fsys, md, fp, b.postOptions...)
 		if err != nil {
-			return nil, fmt.Errorf("could not create blog object for %v: %v", post, err)
+			return nil, fmt.Errorf("could not create blog object for %v: %w", fp, err)
+		}
+		posts = append(posts, post)
+	}
+	if b.sortOrder == SortByManifest && b.manifest != nil {
+		if err := validateManifest(b.manifest, posts); err != nil {
+			err.File = b.manifestFile
+			return nil, err
 		}
-		blogPosts = append(blogPosts, post)
 	}
-	return &blog{
-		Directory: directory,
-		Posts:     blogPosts,
-	}, nil
+	if !b.drafts {
+		posts = slices.DeleteFunc(posts, func(p *blogPost) bool {
+			return !p.IsPublished()
+		})
+	}
+	if err := sortPosts(posts, b.sortOrder, b.manifest); err != nil {
+		return nil, fmt.Errorf("could not sort posts in %v: %v", directory, err)
+	}
+	b.Posts = make([]BlogPost, 0, len(posts))
+	var active []*blogPost
+	for _, p := range posts {
+		if p.IsExpired() {
+			b.archived = append(b.archived, p)
+		} else {
+			b.Posts = append(b.Posts, p)
+			active = append(active, p)
+		}
+	}
+	linkSeries(active)
+	if b.related > 0 {
+		if err := linkRelatedPosts(active, b.related); err != nil {
+			return nil, fmt.Errorf("could not compute related posts: %v", err)
+		}
+	}
+	return b, nil
+}
+
+// Top-level directories of the website that BuildSite generates pages in, e.g. posts/<slug>/index.html and
+// tags/<tag>/index.html. The listing of a section is written to <section>/index.html, so these can't be sections.
+var reservedSections = []string{"posts", "page", TaxonomyTags, TaxonomyCategories, "series", "archive"}
+
+// Returns an error if one of the markdownFiles is in a section whose top-level directory is reserved, see
+// reservedSections.
+func checkSections(markdownFiles []string) error {
+	for _, md := range markdownFiles {
+		top, _, ok := strings.Cut(md, "/")
+		if ok && slices.Contains(reservedSections, top) {
+			return fmt.Errorf("section %v of %v is reserved for generated pages, rename the directory", top, md)
+		}
+	}
+	return nil
+}
+
+// Returns the slash-separated paths of all .md files in the root of fsys and, if recursive is set, its
+// subdirectories.
+func findMarkdownFiles(fsys fs.FS, recursive bool) ([]string, error) {
+	if !recursive {
+		return fs.Glob(fsys, "*.md")
+	}
+	var markdownFiles []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name != "." && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if path.Ext(d.Name()) == ".md" {
+			markdownFiles = append(markdownFiles, name)
+		}
+		return nil
+	})
+	return markdownFiles, err
 }
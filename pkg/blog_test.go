@@ -1,12 +1,17 @@
 package microblog
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -156,3 +161,432 @@ func BenchmarkRenderPostAsync(b *testing.B) {
 		}
 	}
 }
+
+func TestBlogSections(t *testing.T) {
+	d := t.TempDir()
+
+	// same file name in different sections
+	os.MkdirAll(filepath.Join(d, "notes"), 0755)
+	os.MkdirAll(filepath.Join(d, "essays", "2024"), 0755)
+	os.MkdirAll(filepath.Join(d, ".hidden"), 0755)
+	os.WriteFile(filepath.Join(d, "post.md"), []byte("## Top\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "notes", "post.md"), []byte("## Note\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "essays", "2024", "post.md"), []byte("## Essay\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, ".hidden", "post.md"), []byte("## Hidden\nhey"), 0644)
+
+	// subdirectories are ignored by default
+	blog, err := NewBlog(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(blog.GetBlogPosts()) != 1 {
+		t.Errorf("expected only the top-level post, got %v", postNames(blog))
+	}
+
+	blog, err = NewBlog(d, WithSections(), WithSortOrder(SortByNaturalName))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := []string{"essays/2024/post.md", "notes/post.md", "post.md"}
+	if names := postNames(blog); !slices.Equal(names, expected) {
+		t.Errorf("expected posts %v, got %v", expected, names)
+	}
+	if sections := blog.Sections(); !slices.Equal(sections, []string{"essays/2024", "notes"}) {
+		t.Errorf("expected sections essays/2024 and notes, got %v", sections)
+	}
+
+	notes := blog.Section("notes")
+	if notes.GetDirectory() != filepath.Join(d, "notes") {
+		t.Errorf("expected section directory to be %v, got %v", filepath.Join(d, "notes"), notes.GetDirectory())
+	}
+	if names := postNames(notes); !slices.Equal(names, []string{"notes/post.md"}) {
+		t.Errorf("expected only notes/post.md in section, got %v", names)
+	}
+	if section := notes.GetBlogPosts()[0].GetSection(); section != "notes" {
+		t.Errorf("expected post section to be notes, got %v", section)
+	}
+	html, err := notes.RenderPosts()
+	if err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(string(html), "Note") || strings.Contains(string(html), "Top") {
+		t.Errorf("expected section html to contain only the note, got %s", html)
+	}
+
+	if len(blog.Section("unknown").GetBlogPosts()) != 0 {
+		t.Error("expected unknown section to be empty")
+	}
+}
+
+func TestBlogSectionsReserved(t *testing.T) {
+	for _, section := range []string{"posts", "page", "tags", "categories", "series", "archive"} {
+		d := t.TempDir()
+		os.MkdirAll(filepath.Join(d, section, "2024"), 0755)
+		os.WriteFile(filepath.Join(d, "post.md"), []byte("## Top\nhey"), 0644)
+		os.WriteFile(filepath.Join(d, section, "2024", "post.md"), []byte("## Nested\nhey"), 0644)
+
+		if _, err := NewBlog(d, WithSections()); err == nil || !strings.Contains(err.Error(), "reserved") {
+			t.Errorf("expected error for reserved section %v, got %v", section, err)
+		}
+		if err := Check(d, WithSections()); err == nil || !strings.Contains(err.Error(), "reserved") {
+			t.Errorf("expected check to report reserved section %v, got %v", section, err)
+		}
+		// without sections, the subdirectory isn't scanned
+		if _, err := NewBlog(d); err != nil {
+			t.Errorf("expected no error without sections, got %v", err)
+		}
+	}
+}
+
+func TestBlogSectionsPublicationTracking(t *testing.T) {
+	d := t.TempDir()
+
+	os.MkdirAll(filepath.Join(d, "notes"), 0755)
+	os.WriteFile(filepath.Join(d, "post.md"), []byte("## Top\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "notes", "post.md"), []byte("## Note\nhey"), 0644)
+
+	blog, err := NewBlog(d, WithSections(), WithPublicationTracking())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, err := blog.RenderPosts(); err != nil {
+		t.Error(err)
+	}
+
+	// both posts are tracked in the registry of the blog directory under distinct keys
+	if _, err := os.Stat(filepath.Join(d, "notes", "blog.sqlite")); err == nil {
+		t.Error("expected no registry in the section directory")
+	}
+	registry, err := (&sqlitePool).Acquire(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	var count int
+	if err := registry.DB.QueryRow("SELECT COUNT(*) FROM posts WHERE name IN ('post.md', 'notes/post.md');").Scan(&count); err != nil {
+		t.Error(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 rows in the registry, got %v", count)
+	}
+}
+
+func TestBlogDrafts(t *testing.T) {
+	d := t.TempDir()
+
+	future := time.Now().AddDate(0, 0, 7).Format(time.DateOnly)
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("## Published\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("---\ndraft: true\n---\n## Draft\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "c.md"), []byte(fmt.Sprintf("---\npublishAt: %v\n---\n## Scheduled\nhey", future)), 0644)
+	os.WriteFile(filepath.Join(d, "d.md"), []byte("---\npublishAt: 2024-01-01\n---\n## Was scheduled\nhey"), 0644)
+
+	blog, err := NewBlog(d, WithPublicationTracking())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if names := postNames(blog); !slices.Equal(names, []string{"a.md", "d.md"}) {
+		t.Errorf("expected only published posts, got %v", names)
+	}
+	html, err := blog.RenderPosts()
+	if err != nil {
+		t.Error(err)
+	}
+	if strings.Contains(string(html), "draft-banner") {
+		t.Errorf("expected no banner for published posts, got %s", html)
+	}
+
+	// posts whose scheduled date has passed are dated with that date
+	registry, err := (&sqlitePool).Acquire(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	post, _ := NewBlogPost(filepath.Join(d, "d.md"))
+	dt, err := registry.GetPublicationDate(post)
+	if err != nil {
+		t.Error(err)
+	}
+	if dt == nil || *dt != time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("expected publication date of d.md to be 2024-01-01, got %v", dt)
+	}
+
+	blog, err = NewBlog(d, WithPublicationTracking(), WithDrafts())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if names := postNames(blog); !slices.Equal(names, []string{"a.md", "b.md", "c.md", "d.md"}) {
+		t.Errorf("expected drafts to be included, got %v", names)
+	}
+	html, err = blog.RenderPosts()
+	if err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(string(html), `<div class="draft-banner">Draft</div>`) || !strings.Contains(string(html), `<div class="draft-banner">Scheduled</div>`) {
+		t.Errorf("expected banners for the draft and the scheduled post, got %s", html)
+	}
+	if !strings.Contains(string(html), future) {
+		t.Errorf("expected scheduled post to show its scheduled date, got %s", html)
+	}
+
+	// drafts and scheduled posts must not be recorded in the registry
+	var count int
+	if err := registry.DB.QueryRow("SELECT COUNT(*) FROM posts WHERE name IN ('b.md', 'c.md');").Scan(&count); err != nil {
+		t.Error(err)
+	}
+	if count != 0 {
+		t.Errorf("expected no registry entries for drafts, got %v", count)
+	}
+}
+
+func TestBlogExpiredPosts(t *testing.T) {
+	d := t.TempDir()
+
+	future := time.Now().AddDate(0, 0, 7).Format(time.DateOnly)
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("---\nexpires: 2024-01-01\n---\n## Expired\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte(fmt.Sprintf("---\nexpires: %v\n---\n## Expires soon\nhey", future)), 0644)
+
+	blog, err := NewBlog(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if names := postNames(blog); !slices.Equal(names, []string{"b.md"}) {
+		t.Errorf("expected expired post to be omitted, got %v", names)
+	}
+	archived := blog.Archived()
+	if len(archived) != 1 || archived[0].GetName() != "a.md" || !archived[0].IsExpired() {
+		t.Errorf("expected a.md to be archived, got %v", archived)
+		t.FailNow()
+	}
+
+	html, err := blog.RenderPosts()
+	if err != nil {
+		t.Error(err)
+	}
+	if strings.Contains(string(html), "Expired") || strings.Contains(string(html), "archived-banner") {
+		t.Errorf("expected rendered posts to omit the expired post, got %s", html)
+	}
+
+	var buf strings.Builder
+	if err := archived[0].WriteHtml(&buf); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(buf.String(), `<div class="archived-banner">`) {
+		t.Errorf("expected expired post to be rendered with a notice, got %v", buf.String())
+	}
+}
+
+func TestBlogFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"site/blog/a.md":         {Data: []byte("---\ntags: [go]\n---\n## First\nhey")},
+		"site/blog/b.md":         {Data: []byte("## Second\nhello")},
+		"site/blog/notes/c.md":   {Data: []byte("## Note\nhi")},
+		"site/blog/order.txt":    {Data: []byte("b.md\na.md\nnotes/c.md\n")},
+		"site/blog/.hidden/d.md": {Data: []byte("## Hidden\nhi")},
+		"site/post.tmpl":         {Data: []byte("<article>{{.Heading}}</article>")},
+	}
+	blog, err := NewBlogFS(fsys, "site/blog", WithSections(), WithTemplateFS(fsys, "site/post.tmpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := postNames(blog); !slices.Equal(names, []string{"b.md", "a.md", "notes/c.md"}) {
+		t.Errorf("expected posts in manifest order, got %v", names)
+	}
+	if fp := blog.GetBlogPosts()[2].GetFilePath(); fp != "site/blog/notes/c.md" {
+		t.Errorf("expected file path site/blog/notes/c.md, got %v", fp)
+	}
+	if tags := blog.Tags(); len(tags) != 1 || tags[0].Slug != "go" {
+		t.Errorf("expected tag go, got %v", tags)
+	}
+	html, err := blog.RenderPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, heading := range []string{"First", "Second", "Note"} {
+		if !regexp.MustCompile(`<article>\s*` + heading + `\s*</article>`).Match(html) {
+			t.Errorf("expected rendered posts to contain %v, got %s", heading, html)
+		}
+	}
+
+	if _, err := NewBlogFS(fsys, "missing"); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
+
+func TestBlogFSPublicationTracking(t *testing.T) {
+	fsys := fstest.MapFS{"post.md": {Data: []byte("## Title\nhey")}}
+
+	blog, err := NewBlogFS(fsys, ".", WithPublicationTracking())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blog.RenderPosts(); err == nil {
+		t.Error("expected error when tracking publication without registry directory")
+	}
+
+	d := t.TempDir()
+	blog, err = NewBlogFS(fsys, ".", WithPublicationTracking(), WithRegistryDirectory(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blog.RenderPosts(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(d, "blog.sqlite")); err != nil {
+		t.Errorf("expected registry in %v: %v", d, err)
+	}
+}
+
+func TestBlogRenderPostsContext(t *testing.T) {
+	d := t.TempDir()
+	for i := range 20 {
+		os.WriteFile(filepath.Join(d, fmt.Sprintf("post%02d.md", i)), []byte(fmt.Sprintf("## Post %v\nhey", i)), 0644)
+	}
+
+	blog, err := NewBlog(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := blog.RenderPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 3, 50} {
+		html, err := blog.RenderPostsContext(context.Background(), RenderOptions{Workers: workers})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(html, expected) {
+			t.Errorf("expected output with %v workers to match RenderPosts, got %s", workers, html)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := blog.RenderPostsContext(ctx, RenderOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBlogRenderPostsContextErrors(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("no heading"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "c.md"), []byte("## Title\n## Another title"), 0644)
+
+	blog, err := NewBlog(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, render := range []func() ([]byte, error){
+		blog.RenderPostsAsync,
+		func() ([]byte, error) {
+			return blog.RenderPostsContext(context.Background(), RenderOptions{Workers: 2})
+		},
+	} {
+		_, err := render()
+		if err == nil {
+			t.Fatal("expected error when rendering invalid posts")
+		}
+		msg := err.Error()
+		if !strings.Contains(msg, "a.md") || !strings.Contains(msg, "c.md") || strings.Contains(msg, "b.md") {
+			t.Errorf("expected error to list a.md and c.md, got %v", msg)
+		}
+		if strings.Index(msg, "a.md") > strings.Index(msg, "c.md") {
+			t.Errorf("expected errors in the order of the posts, got %v", msg)
+		}
+	}
+}
+
+func TestBlogWriteHtml(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("## First\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("## Second\nhello"), 0644)
+
+	blog, err := NewBlog(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := blog.RenderPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var html bytes.Buffer
+	if err := blog.WriteHtml(&html); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(html.Bytes(), expected) {
+		t.Errorf("expected streamed html to match RenderPosts, got %s", html.Bytes())
+	}
+
+	// posts before the failing post have been written
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("no heading"), 0644)
+	html.Reset()
+	if err := blog.WriteHtml(&html); err == nil || !strings.Contains(err.Error(), "b.md") {
+		t.Errorf("expected error for b.md, got %v", err)
+	}
+	if !strings.Contains(html.String(), "First") || strings.Contains(html.String(), "no heading") {
+		t.Errorf("expected only the first post to be written, got %s", html.String())
+	}
+}
+
+func TestBlogTemplateParsedOnce(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("## Title\nhello"), 0644)
+
+	if _, err := NewBlog(d, WithTemplateString(`<h2>{{.Heading</h2>`)); err == nil || !strings.Contains(err.Error(), "template") {
+		t.Errorf("expected NewBlog to report the template error, got %v", err)
+	}
+
+	templateFp := filepath.Join(t.TempDir(), "post.tmpl")
+	os.WriteFile(templateFp, []byte(`<article>{{.Heading}}</article>`), 0644)
+	blog, err := NewBlog(d, WithTemplateFile(templateFp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the template has been read by NewBlog, rendering doesn't need the file anymore
+	os.Remove(templateFp)
+	html, err := blog.RenderPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(html), "<article>") != 2 {
+		t.Errorf("expected both posts to be rendered with the template file, got %s", html)
+	}
+}
+
+func BenchmarkNewBlogWithTemplateFile(b *testing.B) {
+	d := b.TempDir()
+	writeRandomPosts(b, d, 1000)
+	templateFp := filepath.Join(b.TempDir(), "post.tmpl")
+	os.WriteFile(templateFp, []byte(DefaultOptions.Template), 0644)
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := NewBlog(d, WithTemplateFile(templateFp)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBlogRenderPosts(b *testing.B) {
+	d := b.TempDir()
+	writeRandomPosts(b, d, 1000)
+	blog, err := NewBlog(d)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := blog.RenderPosts(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
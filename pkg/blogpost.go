@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
-	"log"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/gomarkdown/markdown"
@@ -18,8 +20,6 @@ import (
 	"github.com/gomarkdown/markdown/parser"
 )
 
-var htmlRenderer *html.Renderer
-
 type defaultOptions struct {
 	Template                  string
 	Backend                   RegistryType
@@ -32,9 +32,13 @@ type defaultOptions struct {
 var DefaultOptions defaultOptions = defaultOptions{
 	Template: `
 	<div class="blog-post">
+		{{if .Draft}}<div class="draft-banner">Draft</div>{{else if .IsScheduled}}<div class="draft-banner">Scheduled</div>{{end}}
+		{{if .IsExpired}}<div class="archived-banner">This post has been archived.</div>{{end}}
 		<h2>{{.Heading}}</h2>
 		<span class="dt-posted">{{.DtPosted}}</span>
 		{{.Content}}
+		{{with .Series}}<div class="series">Part {{.Position $}} of the series <a href="{{.URL}}">{{.Name}}</a></div>{{end}}
+		{{with .GetTags}}<ul class="tags">{{range .}}<li><a href="{{.URL}}">{{.Name}}</a></li>{{end}}</ul>{{end}}
 	</div>
 	`,
 	Backend:                   SQLite,
@@ -45,18 +49,43 @@ var DefaultOptions defaultOptions = defaultOptions{
 type BlogPost interface {
 	GetFilePath() string
 	GetName() string
+	GetSection() string
+	GetTags() []Term
+	GetCategories() []Term
+	GetTitle() string
+	GetSlug() string
+	GetURL() string
+	Series() *Series
+	Prev() BlogPost
+	Next() BlogPost
+	Related() []BlogPost
+	GetPublicationDate() (*time.Time, error)
+	IsPublished() bool
+	IsScheduled() bool
+	IsExpired() bool
 	Markdown() (string, error)
+	Render() (RenderedPost, error)
 	WriteHtml(io.Writer) error
 }
 
 type blogPost struct {
+	Metadata
 	FilePath            string
+	Section             string
+	fsys                fs.FS  // file system rooted at the directory of the Blog the post belongs to
+	name                string // slash-separated path of the post in fsys
+	registryDirectory   string // directory of the publication tracking database
+	series              *Series
+	prev                *blogPost
+	next                *blogPost
+	related             []*blogPost
 	publicationTracking bool
 	PublicationDate     *time.Time
-	template            string
-	Heading             string
-	Content             string
-	DtPosted            string
+	template            *postTemplate
+	partials            *templatePartials
+	funcs               *templateFuncs
+	baseURL             *templateBaseURL
+	renderCache         bool
 }
 
 // Create a new BlogPost
@@ -70,41 +99,124 @@ type blogPost struct {
 // The template itself must be written using the Golang template language and contain the same variables as the
 // default template. You can however change the tags and classes.
 // You can access the default template at microblog.Template.
-func NewBlogPost(fp string, options ...func(*blogPost) error) (BlogPost, error) {
+func NewBlogPost(fp string, options ...PostOption) (BlogPost, error) {
 	if _, err := os.Stat(fp); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil, fmt.Errorf("file %v does not exist", fp)
 		}
 		return nil, fmt.Errorf("could not acquire file info for %v: %v", fp, err)
 	}
-	b := &blogPost{FilePath: fp}
-	b.template = DefaultOptions.Template
+	dir := filepath.Dir(fp)
+	return newBlogPost(os.DirFS(dir), filepath.Base(fp), fp, append([]PostOption{WithRegistryDirectory(dir)}, options...)...)
+}
+
+// Creates a new blogPost for the file name in fsys. fp is the path of the file reported by GetFilePath.
+func newBlogPost(fsys fs.FS, name string, fp string, options ...PostOption) (*blogPost, error) {
+	b := &blogPost{FilePath: fp, fsys: fsys, name: name}
+	if section := path.Dir(name); section != "." {
+		b.Section = section
+	}
 	for _, o := range options {
 		if err := o(b); err != nil {
 			return nil, err
 		}
 	}
+	tmpl, err := combineTemplate(b.template, b.partials, b.funcs, b.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	b.template = tmpl
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file %v: %v", fp, err)
+	}
+	if b.Metadata, err = parseFrontMatter(content); err != nil {
+		return nil, &PostError{Path: fp, Line: frontMatterErrorLine(content, err), Kind: KindFrontMatter, Err: err}
+	}
 	return b, nil
 }
 
 // options
 
-// Override the default template (microblog.DefaultOptions.Template) with a string
-func WithTemplateString(s string) func(*blogPost) error {
+// Configures a BlogPost. Post options can be passed to NewBlogPost, or to NewBlog to apply them to all posts.
+type PostOption func(*blogPost) error
+
+func (o PostOption) applyToBlog(b *blog) error {
+	b.postOptions = append(b.postOptions, o)
+	return nil
+}
+
+// Override the default template (microblog.DefaultOptions.Template) with a string.
+// The template is parsed once and shared by all posts the option is applied to.
+func WithTemplateString(s string) PostOption {
+	return withTemplate(newPostTemplate(func() (string, error) {
+		return s, nil
+	}))
+}
+
+// Override the default template (microblog.DefaultOptions.Template) with a template file.
+// The file is read when the first post is created, e.g. by NewBlog, and not again for further posts.
+func WithTemplateFile(fp string) PostOption {
+	return withTemplate(newPostTemplate(func() (string, error) {
+		content, err := os.ReadFile(fp)
+		return string(content), err
+	}))
+}
+
+// Override the default template (microblog.DefaultOptions.Template) with the file name in fsys
+func WithTemplateFS(fsys fs.FS, name string) PostOption {
+	return withTemplate(newPostTemplate(func() (string, error) {
+		content, err := fs.ReadFile(fsys, name)
+		return string(content), err
+	}))
+}
+
+func withTemplate(t *postTemplate) PostOption {
 	return func(b *blogPost) error {
-		b.template = s
+		b.template = t
 		return nil
 	}
 }
 
-// Override the default template (microblog.DefaultOptions.Template) with a template file
-func WithTemplateFile(fp string) func(*blogPost) error {
+// Parse the files in fsys matching the patterns (see fs.Glob) together with the post template, e.g. layouts and
+// partials that the template includes with {{template "name" .}}. Every file is a template named by its base name.
+// The files are parsed before the post template, so the post template can redefine their blocks:
+//
+//	blog, err := microblog.NewBlog("/path/to/md/directory",
+//		microblog.WithTemplateFile("/path/to/html/template"),
+//		microblog.WithPartials(os.DirFS("/path/to/src"), "_layouts/*.tmpl", "_partials/*.tmpl"))
+func WithPartials(fsys fs.FS, patterns ...string) PostOption {
+	partials := &templatePartials{fsys: fsys, patterns: patterns}
 	return func(b *blogPost) error {
-		content, err := os.ReadFile(fp)
-		if err != nil {
-			return err
-		}
-		b.template = string(content)
+		b.partials = partials
+		return nil
+	}
+}
+
+// Make the functions funcs available in the post template, in addition to the functions of TemplateFuncs. Functions
+// with the same name as one of these replace it. The render cache (see WithRenderCache) tells functions apart by
+// their name only, so functions must return the same results in every build, use WithBaseURL for absURL.
+//
+//	blog, err := microblog.NewBlog("/path/to/md/directory",
+//		microblog.WithTemplateFuncs(template.FuncMap{"upper": strings.ToUpper}),
+//		microblog.WithTemplateString(`<h2>{{upper .GetTitle}}</h2>{{.Content}}`))
+func WithTemplateFuncs(funcs template.FuncMap) PostOption {
+	f := &templateFuncs{funcs: funcs}
+	return func(b *blogPost) error {
+		b.funcs = f
+		return nil
+	}
+}
+
+// Resolve paths against baseURL with absURL in the post template, see TemplateFuncs.
+//
+//	blog, err := microblog.NewBlog("/path/to/md/directory",
+//		microblog.WithBaseURL("https://example.com"),
+//		microblog.WithTemplateString(`<h2><a href="{{absURL .GetURL}}">{{.Heading}}</a></h2>{{.Content}}`))
+func WithBaseURL(baseURL string) PostOption {
+	u := &templateBaseURL{url: baseURL}
+	return func(b *blogPost) error {
+		b.baseURL = u
 		return nil
 	}
 }
@@ -112,13 +224,23 @@ func WithTemplateFile(fp string) func(*blogPost) error {
 // Enable publication tracking using a database backend.
 // With this option enabled, the date of the first blogpost.WriteHtml call
 // will be stored in a database backend and used in subsequent blogpost.WriteHtml calls
-func WithPublicationTracking() func(*blogPost) error {
+func WithPublicationTracking() PostOption {
 	return func(b *blogPost) error {
 		b.publicationTracking = true
 		return nil
 	}
 }
 
+// Store the database used for publication tracking in directory. Defaults to the blog directory for posts created
+// with NewBlog and NewBlogPost. Blogs created with NewBlogFS have no default, so publication tracking requires
+// this option.
+func WithRegistryDirectory(directory string) PostOption {
+	return func(b *blogPost) error {
+		b.registryDirectory = directory
+		return nil
+	}
+}
+
 // methods
 
 // Returns the file path of the markdown file that the BlogPost represents.
@@ -127,20 +249,165 @@ func (p *blogPost) GetFilePath() string {
 }
 
 // Returns the file name of the markdown file that the BlogPost represents. This is used as
-// a unique identifier in the storage backend.
+// a unique identifier in the storage backend. For posts in a subdirectory of the blog directory,
+// the name is the slash-separated path relative to the blog directory, e.g. "notes/post.md".
 func (p *blogPost) GetName() string {
-	return filepath.Base(p.GetFilePath())
+	return p.name
+}
+
+// Returns the section of the post, i.e. the slash-separated path of the subdirectory of the blog directory
+// that contains the post. Posts at the top level of the blog directory have no section ("").
+func (p *blogPost) GetSection() string {
+	return p.Section
 }
 
 // Returns the plain file content of the .md file that the BlogPost represents.
 func (p *blogPost) Markdown() (string, error) {
-	file, err := os.ReadFile(p.FilePath)
+	file, err := fs.ReadFile(p.fsys, p.name)
 	if err != nil {
 		return "", fmt.Errorf("could not read file %v: %v", p.FilePath, err)
 	}
 	return string(file), nil
 }
 
+// Reports whether the publication date of the post is fetched from/written to a database backend.
+func (p *blogPost) tracksPublication() bool {
+	return DefaultOptions.EnablePublicationTracking || p.publicationTracking
+}
+
+// Reports whether the post is published, i.e. it is not a draft and its scheduled publication date
+// (publishAt in the front matter), if any, has passed.
+func (p *blogPost) IsPublished() bool {
+	return !p.Draft && !p.IsScheduled()
+}
+
+// Reports whether the post is scheduled for publication at a future date.
+func (p *blogPost) IsScheduled() bool {
+	return p.PublishAt != nil && p.PublishAt.After(time.Now())
+}
+
+// Reports whether the expiry date of the post (expires in the front matter) has passed.
+func (p *blogPost) IsExpired() bool {
+	return p.Expires != nil && !p.Expires.After(time.Now())
+}
+
+// Returns the slug of the post, i.e. its identifier in URLs. Unless set in the front matter, the slug is derived
+// from the file name without numeric prefix, e.g. "001_Hello World.md" becomes "hello-world". For posts in a
+// section, the slugified section path is prepended, e.g. "notes/hello-world".
+func (p *blogPost) GetSlug() string {
+	slug := p.Slug
+	if slug == "" {
+		name := strings.TrimSuffix(path.Base(p.GetName()), path.Ext(p.GetName()))
+		slug = slugify(numericPrefix.ReplaceAllString(name, ""))
+	}
+	if p.Section != "" {
+		var parts []string
+		for _, part := range strings.Split(p.Section, "/") {
+			parts = append(parts, slugify(part))
+		}
+		slug = path.Join(append(parts, slug)...)
+	}
+	return slug
+}
+
+// Returns the series the post belongs to, or nil if it isn't part of a series. Series are only available for
+// posts created with NewBlog.
+func (p *blogPost) Series() *Series {
+	return p.series
+}
+
+// Returns the previous post in the series, or nil if the post is the first one or isn't part of a series.
+func (p *blogPost) Prev() BlogPost {
+	if p.prev == nil {
+		return nil
+	}
+	return p.prev
+}
+
+// Returns the next post in the series, or nil if the post is the last one or isn't part of a series.
+func (p *blogPost) Next() BlogPost {
+	if p.next == nil {
+		return nil
+	}
+	return p.next
+}
+
+// Returns the most related posts, most related first. Related posts are only computed for posts created with
+// NewBlog and the WithRelatedPosts option.
+func (p *blogPost) Related() []BlogPost {
+	related := make([]BlogPost, 0, len(p.related))
+	for _, r := range p.related {
+		related = append(related, r)
+	}
+	return related
+}
+
+// Returns the text of the heading of the post without any markup, or an empty string if the post can't be read
+// or has no heading.
+func (p *blogPost) GetTitle() string {
+	file, err := fs.ReadFile(p.fsys, p.name)
+	if err != nil {
+		return ""
+	}
+	_, body := splitFrontMatter(file)
+	for _, c := range parser.New().Parse(body).GetChildren() {
+		if heading, ok := c.(*ast.Heading); ok {
+			var title strings.Builder
+			ast.WalkFunc(heading, func(node ast.Node, entering bool) ast.WalkStatus {
+				if leaf := node.AsLeaf(); entering && leaf != nil {
+					title.Write(leaf.Literal)
+				}
+				return ast.GoToNext
+			})
+			return strings.TrimSpace(title.String())
+		}
+	}
+	return ""
+}
+
+// Returns the URL of the permalink page of the post, e.g. /posts/hello-world/.
+func (p *blogPost) GetURL() string {
+	return fmt.Sprintf("/posts/%v/", p.GetSlug())
+}
+
+// Returns the date the post has been published on: the date from the front matter if set, otherwise the date
+// recorded in the database backend if publication tracking is enabled, otherwise the scheduled publication date.
+// Returns nil if none of these are known. Unlike WriteHtml, this method never records a publication date.
+func (p *blogPost) GetPublicationDate() (*time.Time, error) {
+	if p.Date != nil {
+		return p.Date, nil
+	}
+	if p.tracksPublication() && p.IsPublished() {
+		backend, err := p.registry()
+		if err != nil {
+			return nil, err
+		}
+		dtPosted, err := backend.GetPublicationDate(p)
+		if err != nil || dtPosted != nil {
+			return dtPosted, err
+		}
+	}
+	return p.PublishAt, nil
+}
+
+// Returns the database backend that tracks the publication date of the post.
+func (p *blogPost) registry() (*sqliteRegistry, error) {
+	if p.registryDirectory == "" {
+		return nil, fmt.Errorf("publication tracking of %v requires a registry directory, see WithRegistryDirectory", p.FilePath)
+	}
+	return acquireRegistry(p.registryDirectory)
+}
+
+// Returns the tags listed in the front matter of the post.
+func (p *blogPost) GetTags() []Term {
+	return newTerms(TaxonomyTags, p.Tags)
+}
+
+// Returns the categories listed in the front matter of the post.
+func (p *blogPost) GetCategories() []Term {
+	return newTerms(TaxonomyCategories, p.Categories)
+}
+
 func (p *blogPost) String() string {
 	c, err := p.Markdown()
 	if err != nil {
@@ -149,51 +416,108 @@ func (p *blogPost) String() string {
 	return c
 }
 
-// Renders BlogPost as HTML. Method accepts the io.Writer interface which means you can write to a bytes.Buffer
-// and all other structs that implement the io.Writer interface,
-// for example:
-//
-//	var buf bytes.Buffer
-//	err := post.WriteHtml(&buf)
-//
-// To change the underlying HTML template, use the `WithTemplateFile`/`WithTemplateString` options to `NewBlog()`
-// or `NewBlogPost()`. To control whether the publication date rendered is fetched from/written to a database backend,
-// use the `WithPublicationTracking()` option.
-func (p *blogPost) WriteHtml(w io.Writer) error {
-	file, err := os.ReadFile(p.FilePath)
+// The result of rendering a BlogPost, see BlogPost.Render. It is the data the post template is executed with, so
+// besides the rendered HTML, the methods of the post and its front matter (e.g. {{.GetURL}} and {{.Tags}}) are
+// available in the template. A RenderedPost is a value that is not affected by later renders of the same post.
+type RenderedPost struct {
+	BlogPost                // the post that has been rendered
+	Metadata                // front matter of the post
+	Section   string        // section of the post, see BlogPost.GetSection
+	Heading   template.HTML // heading as HTML, without the enclosing <h2> or <h3> tag
+	Content   template.HTML // paragraphs as HTML
+	Published time.Time     // publication date, see BlogPost.Render
+	DtPosted  string        // publication date in the format YYYY-MM-DD
+}
+
+// Flags of the renderer for the HTML of a post.
+const htmlFlags = html.CommonFlags | html.HrefTargetBlank
+
+// Returns a new renderer for the HTML of a post. Renderers keep state while rendering a document,
+// so every render needs its own.
+func newHtmlRenderer() *html.Renderer {
+	return html.NewRenderer(html.RendererOptions{Flags: htmlFlags})
+}
+
+// Renders the Markdown of the post as HTML without executing the template. The publication date is the date from
+// the front matter if set, otherwise the date recorded by publication tracking (recording today or the scheduled
+// publication date on the first render), otherwise the scheduled publication date, otherwise today. Render is
+// safe for concurrent use.
+func (p *blogPost) Render() (RenderedPost, error) {
+	file, err := fs.ReadFile(p.fsys, p.name)
 	if err != nil {
-		return fmt.Errorf("could not read file %v: %v", p.FilePath, err)
+		return RenderedPost{}, fmt.Errorf("could not read file %v: %v", p.FilePath, err)
 	}
-	markdownParser := parser.New()
-	if htmlRenderer == nil {
-		htmlFlags := html.CommonFlags | html.HrefTargetBlank
-		opts := html.RendererOptions{Flags: htmlFlags}
-		htmlRenderer = html.NewRenderer(opts)
+	return p.render(file)
+}
+
+// Renders file, the content of the Markdown file of the post, see Render.
+func (p *blogPost) render(file []byte) (RenderedPost, error) {
+	rendered, err := p.renderMarkdown(file)
+	if err != nil {
+		return RenderedPost{}, err
 	}
 
-	if markdownParser == nil {
-		log.Fatal("parser is nil")
+	var dtPosted *time.Time
+
+	// add publication date
+	if p.Date != nil {
+		dtPosted = p.Date
+	} else if p.tracksPublication() && p.IsPublished() { // drafts and scheduled posts don't get a date
+		backend, err := p.registry()
+		if err != nil {
+			return RenderedPost{}, err
+		}
+		// use the scheduled date if the post hasn't been published before
+		dtPosted, err = backend.GetOrSetPublicationDate(p, p.PublishAt)
+		if err != nil {
+			return RenderedPost{}, err
+		}
+	} else if p.PublishAt != nil {
+		dtPosted = p.PublishAt
+	} else {
+		today := time.Now()
+		dtPosted = &today
+	}
+	rendered.Published = *dtPosted
+	rendered.DtPosted = dtPosted.Format(time.DateOnly)
+	return rendered, nil
+}
+
+// Renders the heading and paragraphs of file, the content of the Markdown file of the post, without determining
+// the publication date.
+func (p *blogPost) renderMarkdown(file []byte) (RenderedPost, error) {
+	_, body := splitFrontMatter(file)
+	doc := parser.New().Parse(body)
+	htmlRenderer := newHtmlRenderer()
+
+	// problems are reported with the position of the top-level block they concern
+	positions := blockPositions(body, bytes.Count(file[:len(file)-len(body)], []byte("\n")))
+	postError := func(block int, kind ErrorKind, err error) error {
+		e := &PostError{Path: p.FilePath, Kind: kind, Err: err}
+		if block < len(positions) {
+			e.Line, e.Column = positions[block][0], positions[block][1]
+		}
+		return e
 	}
-	doc := markdownParser.Parse(file)
 
 	nodes := doc.GetChildren()
 	var heading *ast.Heading
 	var paragraphs []*ast.Paragraph = make([]*ast.Paragraph, 0, len(nodes))
-	for _, c := range nodes {
+	for i, c := range nodes {
 		switch n := c.(type) {
 		case *ast.Heading:
 			if heading != nil {
-				return errors.New("more than one heading in blog post")
+				return RenderedPost{}, postError(i, KindMultipleHeadings, errors.New("more than one heading in blog post"))
 			}
 			heading = n
 		case *ast.Paragraph:
 			paragraphs = append(paragraphs, n)
 		default:
-			return fmt.Errorf("unexpected node of type %T", c)
+			return RenderedPost{}, postError(i, KindUnexpectedNode, fmt.Errorf("unexpected node of type %T", c))
 		}
 	}
 	if heading == nil {
-		return errors.New("no heading found")
+		return RenderedPost{}, postError(0, KindNoHeading, errors.New("no heading found"))
 	}
 	r := strings.NewReplacer(
 		"<h2>", "",
@@ -201,56 +525,41 @@ func (p *blogPost) WriteHtml(w io.Writer) error {
 		"<h3>", "",
 		"</h3>", "",
 	)
-	p.Heading = r.Replace(string(markdown.Render(heading, htmlRenderer)))
+	rendered := RenderedPost{BlogPost: p, Metadata: p.Metadata.clone(), Section: p.Section}
+	rendered.Heading = template.HTML(r.Replace(string(markdown.Render(heading, htmlRenderer))))
 	if len(paragraphs) == 0 {
-		return errors.New("no paragraphs in blog post")
+		return RenderedPost{}, postError(slices.Index(nodes, ast.Node(heading)), KindNoParagraphs, errors.New("no paragraphs in blog post"))
 	}
 	var s strings.Builder
 	for idx := range paragraphs {
 		s.WriteString(string(markdown.Render(paragraphs[idx], htmlRenderer)))
 	}
-	p.Content = s.String()
-
-	var dtPosted *time.Time
+	rendered.Content = template.HTML(s.String())
+	return rendered, nil
+}
 
-	// add publication date
-	if DefaultOptions.EnablePublicationTracking || p.publicationTracking {
-		switch DefaultOptions.Backend {
-		case SQLite:
-			backend, err := (&sqlitePool).Acquire(filepath.Dir(p.GetFilePath()))
-			if err != nil {
-				return err
-			}
-			dtPosted, err = backend.GetPublicationDate(p)
-			if err != nil {
-				return err
-			}
-			if dtPosted == nil { // hasn't been published before
-				dtPosted, err = backend.SetPublicationDate(p, nil)
-				if err != nil {
-					return err
-				}
-			}
-		default:
-			return fmt.Errorf("unrecognised backend option %v", DefaultOptions.Backend)
-		}
-	} else {
-		today := time.Now()
-		dtPosted = &today
+// Renders BlogPost as HTML. Method accepts the io.Writer interface which means you can write to a bytes.Buffer
+// and all other structs that implement the io.Writer interface,
+// for example:
+//
+//	var buf bytes.Buffer
+//	err := post.WriteHtml(&buf)
+//
+// To change the underlying HTML template, use the `WithTemplateFile`/`WithTemplateString` options to `NewBlog()`
+// or `NewBlogPost()`. The template is executed with the RenderedPost returned by Render. To control whether the
+// publication date rendered is fetched from/written to a database backend, use the `WithPublicationTracking()` option.
+func (p *blogPost) WriteHtml(w io.Writer) error {
+	if p.renderCache {
+		return p.writeCachedHtml(w)
 	}
-	p.DtPosted = dtPosted.Format(time.DateOnly)
-
-	tmpl, err := template.New("post").Parse(p.template)
+	rendered, err := p.Render()
 	if err != nil {
-		return fmt.Errorf("could not open template: %v", err)
-	}
-	if tmpl.Tree == nil {
-		return errors.New("template tree is empty")
+		return err
 	}
 
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, p); err != nil {
-		return fmt.Errorf("could not generate output: %v", err)
+	if err := p.template.tmpl.Execute(&buf, rendered); err != nil {
+		return templateError(p.FilePath, err)
 	}
 	if _, err := w.Write(buf.Bytes()); err != nil {
 		return err
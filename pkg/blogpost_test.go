@@ -2,10 +2,16 @@ package microblog
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"html/template"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -99,6 +105,85 @@ func TestBlogpostHtml(t *testing.T) {
 	}
 }
 
+func TestBlogpostRender(t *testing.T) {
+	d := t.TempDir()
+
+	// write dummy blog post
+	postFp := filepath.Join(d, "test.md")
+	content := "---\ndate: 2024-05-01\npublishAt: 2024-04-01\nexpires: 2099-01-01\ntags: [go]\n---\n## Title *emphasised*\nhey [google](https://google.com)."
+	os.WriteFile(postFp, []byte(content), 0644)
+
+	post, err := NewBlogPost(postFp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := post.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(rendered.Heading)) != "Title <em>emphasised</em>" {
+		t.Errorf("unexpected heading %q", rendered.Heading)
+	}
+	if strings.TrimSpace(string(rendered.Content)) != `<p>hey <a href="https://google.com" target="_blank">google</a>.</p>` {
+		t.Errorf("unexpected content %q", rendered.Content)
+	}
+	if rendered.DtPosted != "2024-05-01" || !rendered.Published.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected publication date %v (%v)", rendered.Published, rendered.DtPosted)
+	}
+	if rendered.GetName() != "test.md" || len(rendered.Tags) != 1 || rendered.Tags[0] != "go" {
+		t.Errorf("expected rendered post to expose the post and its metadata, got %+v", rendered)
+	}
+
+	// the result doesn't share state with the post or other renders
+	rendered.Tags[0] = "changed"
+	*rendered.Date = rendered.Date.AddDate(1, 0, 0)
+	*rendered.PublishAt = rendered.PublishAt.AddDate(1, 0, 0)
+	*rendered.Expires = rendered.Expires.AddDate(-100, 0, 0)
+	again, err := post.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Tags[0] != "go" || post.GetTags()[0].Name != "go" {
+		t.Errorf("expected tags of the post to be unaffected, got %v", again.Tags)
+	}
+	if again.DtPosted != "2024-05-01" || again.Date.Format(time.DateOnly) != "2024-05-01" {
+		t.Errorf("expected date of the post to be unaffected, got %v", again.Date)
+	}
+	if again.PublishAt.Format(time.DateOnly) != "2024-04-01" || again.Expires.Format(time.DateOnly) != "2099-01-01" || post.IsExpired() {
+		t.Errorf("expected publishAt and expires of the post to be unaffected, got %v and %v", again.PublishAt, again.Expires)
+	}
+}
+
+func TestBlogpostRenderConcurrent(t *testing.T) {
+	d := t.TempDir()
+	for i := range 10 {
+		os.WriteFile(filepath.Join(d, fmt.Sprintf("post%v.md", i)), []byte(fmt.Sprintf("## Post %v\nhey [google](https://google.com).", i)), 0644)
+	}
+	blog, err := NewBlog(d, WithPublicationTracking())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run with -race: the same posts are rendered by several goroutines at once
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, p := range blog.GetBlogPosts() {
+				var html bytes.Buffer
+				if err := p.WriteHtml(&html); err != nil {
+					t.Error(err)
+				}
+			}
+			if _, err := blog.RenderPostsContext(context.Background(), RenderOptions{Workers: 4}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func TestBlogpostWithTemplateString(t *testing.T) {
 	d := t.TempDir()
 
@@ -163,6 +248,105 @@ func TestBlogpostWithTemplateFile(t *testing.T) {
 	}
 }
 
+func TestBlogpostWithPartials(t *testing.T) {
+	src := fstest.MapFS{
+		"_layouts/post.tmpl":    {Data: []byte(`<article>{{block "title" .}}<h2>{{.Heading}}</h2>{{end}}{{.Content}}{{template "footer.tmpl" .}}</article>`)},
+		"_partials/footer.tmpl": {Data: []byte(`<footer>{{.DtPosted}}</footer>`)},
+		"_partials/unused.tmpl": {Data: []byte(`{{define "unused"}}{{.Heading}}{{end}}`)},
+	}
+	posts := fstest.MapFS{"a.md": {Data: []byte("## Title\nhey")}, "b.md": {Data: []byte("## Other\nhi")}}
+
+	for template, expected := range map[string]string{
+		`{{template "post.tmpl" .}}`: `<article><h2>Title</h2><p>hey</p><footer>2024-01-01</footer></article>`,
+		`{{define "title"}}<h3>{{.Heading}}</h3>{{end}}{{template "post.tmpl" .}}`: `<article><h3>Title</h3><p>hey</p><footer>2024-01-01</footer></article>`,
+		"": `<h2>Title</h2><span class="dt-posted">2024-01-01</span>`, // partials don't affect the default template
+	} {
+		options := []Option{WithPartials(src, "_layouts/*.tmpl", "_partials/*.tmpl")}
+		if template != "" {
+			options = append(options, WithTemplateString(template))
+		}
+		blog, err := NewBlogFS(posts, ".", options...)
+		if err != nil {
+			t.Fatal("could not create blog:", err)
+		}
+		a, b := blog.GetBlogPosts()[0].(*blogPost), blog.GetBlogPosts()[1].(*blogPost)
+		if a.template != b.template {
+			t.Error("expected posts to share the combined template")
+		}
+		date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		a.Date = &date
+		var html bytes.Buffer
+		if err := a.WriteHtml(&html); err != nil {
+			t.Fatal("could not write html:", err)
+		}
+		if !strings.Contains(strings.NewReplacer("\n", "", "\t", "").Replace(html.String()), expected) {
+			t.Errorf("expected html to contain %q, got %q", expected, html.String())
+		}
+	}
+
+	// partials are validated as part of the post template
+	src["_partials/footer.tmpl"] = &fstest.MapFile{Data: []byte(`<footer>{{.Date.Yaer}}</footer>`)}
+	_, err := NewBlogFS(posts, ".", WithPartials(src, "_layouts/*.tmpl", "_partials/*.tmpl"), WithTemplateString(`{{template "post.tmpl" .}}`))
+	if err == nil || !strings.Contains(err.Error(), "footer.tmpl:1:15: can't evaluate field Yaer in type *time.Time, did you mean Year?") {
+		t.Error("expected error for unknown field in partial, got", err)
+	}
+}
+
+func TestBlogpostWithTemplateFuncs(t *testing.T) {
+	posts := fstest.MapFS{"a.md": {Data: []byte("---\ndate: 2024-03-05\n---\n## Title\nhey")}}
+
+	blog, err := NewBlogFS(posts, ".",
+		WithTemplateFuncs(template.FuncMap{"upper": strings.ToUpper, "slugify": func(s string) string { return "custom" }}),
+		WithTemplateString(`<h2>{{upper .GetTitle}}</h2>{{.Published | date "Jan 2, 2006"}} {{slugify .GetTitle}}`))
+	if err != nil {
+		t.Fatal("could not create blog:", err)
+	}
+	var html bytes.Buffer
+	if err := blog.GetBlogPosts()[0].WriteHtml(&html); err != nil {
+		t.Fatal("could not write html:", err)
+	}
+	if expected := "<h2>TITLE</h2>Mar 5, 2024 custom"; !strings.Contains(strings.ReplaceAll(html.String(), "\n", ""), expected) {
+		t.Errorf("expected html to contain %q, got %q", expected, html.String())
+	}
+
+	// the template functions are available without the option
+	blog, err = NewBlogFS(posts, ".", WithTemplateString(`{{readingTime .Content}} {{slugify .GetTitle}}`))
+	if err != nil {
+		t.Fatal("could not create blog:", err)
+	}
+	html.Reset()
+	if err := blog.GetBlogPosts()[0].WriteHtml(&html); err != nil {
+		t.Fatal("could not write html:", err)
+	}
+	if expected := "1 title"; !strings.Contains(html.String(), expected) {
+		t.Errorf("expected html to contain %q, got %q", expected, html.String())
+	}
+}
+
+func TestBlogpostEscaping(t *testing.T) {
+	posts := fstest.MapFS{"a.md": {Data: []byte("---\ntags: ['\"><script>alert(1)</script>', 'javascript:alert(1)']\n---\n## Title <em>x</em>\nhey <b>there</b>")}}
+	blog, err := NewBlogFS(posts, ".", WithTemplateString(
+		`<h2>{{.Heading}}</h2><p title="{{index .Tags 0}}">{{index .Tags 0}}</p><a href="{{index .Tags 1}}">x</a><script>var tag = {{index .Tags 0}};</script>{{.Content}}`))
+	if err != nil {
+		t.Fatal("could not create blog:", err)
+	}
+	var html bytes.Buffer
+	if err := blog.GetBlogPosts()[0].WriteHtml(&html); err != nil {
+		t.Fatal("could not write html:", err)
+	}
+	for _, expected := range []string{
+		"<h2>Title <em>x</em>", // Markdown is inserted as HTML
+		"<p>hey <b>there</b></p>",
+		`<p title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;">&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;</p>`,
+		`<a href="#ZgotmplZ">`,
+		`var tag = "\"\u003e\u003cscript\u003ealert(1)\u003c/script\u003e";`,
+	} {
+		if !strings.Contains(html.String(), expected) {
+			t.Errorf("expected html to contain %q, got %q", expected, html.String())
+		}
+	}
+}
+
 func TestBlogpostWithPublicationTracking(t *testing.T) {
 	d := t.TempDir()
 
@@ -236,3 +420,53 @@ func TestBlogpostWithPublicationTracking(t *testing.T) {
 		t.Errorf("generated html (%s) does not match '%v'", buf.Bytes(), expHtml2)
 	}
 }
+
+func TestBlogpostFrontMatter(t *testing.T) {
+	d := t.TempDir()
+
+	// write dummy blog post
+	postFp := filepath.Join(d, "test.md")
+	os.WriteFile(postFp, []byte("---\ndate: 2024-01-02\n---\n## Title\nhey [google](https://google.com)."), 0644)
+
+	post, err := NewBlogPost(postFp, WithPublicationTracking())
+	if err != nil {
+		t.Errorf("could not instantiate post object: %v", err)
+		t.FailNow()
+	}
+
+	// the date from the front matter takes precedence over publication tracking
+	var html bytes.Buffer
+	if err := post.WriteHtml(&html); err != nil {
+		t.Error("could not write html:", err)
+	}
+	expHtml := regexp.MustCompile(`<div class="blog-post">\s*<h2>\s*Title\s*</h2>\s*<span class="dt-posted">2024-01-02</span>\s*<p>hey <a href="https://google.com" target="_blank">google</a>\.</p>\s*</div>`)
+	if !expHtml.Match(html.Bytes()) {
+		t.Errorf("generated html (%s) does not match '%v'", html.Bytes(), expHtml)
+	}
+}
+
+func TestBlogpostSlug(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_Hello World.md": {Data: []byte("## Title\nhey")},
+		"custom.md":          {Data: []byte("---\nslug: my-slug\n---\n## Title\nhey")},
+		"My Notes/note.md":   {Data: []byte("## Title\nhey")},
+	}
+
+	for name, expected := range map[string]string{
+		"001_Hello World.md": "hello-world",
+		"custom.md":          "my-slug",
+		"My Notes/note.md":   "my-notes/note",
+	} {
+		post, err := newBlogPost(fsys, name, name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if post.GetSlug() != expected {
+			t.Errorf("expected slug of %v to be %v, got %v", name, expected, post.GetSlug())
+		}
+		if post.GetURL() != "/posts/"+expected+"/" {
+			t.Errorf("expected url of %v to be /posts/%v/, got %v", name, expected, post.GetURL())
+		}
+	}
+}
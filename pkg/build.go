@@ -0,0 +1,344 @@
+package microblog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yosssi/gohtml"
+)
+
+// Recursively copies the contents of src to the directory dst, which is created if necessary.
+// Template files (*.tmpl) and the directories of layouts and partials are skipped.
+func overwriteDirectoryContents(src fs.FS, dst string, force bool) error {
+	dstContents, err := os.ReadDir(dst)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if len(dstContents) != 0 && !force {
+		return fmt.Errorf("directory %v is not empty. use flag force to overwrite", dst)
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return fmt.Errorf("could not remove directory %v: %v", dst, err)
+	}
+	return fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("could not read the directory %v: %v", name, err)
+		}
+		to := filepath.Join(dst, filepath.FromSlash(name))
+		if d.IsDir() && isPartialDirectory(name) {
+			return fs.SkipDir
+		}
+		if d.IsDir() {
+			if err := os.MkdirAll(to, 0755); err != nil {
+				return fmt.Errorf("could not create directory %v: %v", to, err)
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, ".tmpl") { // ignore template files, they're handled separately
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("could not acquire file info for %v: %v", name, err)
+		}
+		content, err := fs.ReadFile(src, name)
+		if err != nil {
+			return fmt.Errorf("could not read file %v: %v", name, err)
+		}
+		perm := info.Mode().Perm()
+		if perm == 0 { // e.g. fstest.MapFS without a mode
+			perm = 0644
+		}
+		if err := os.WriteFile(to, content, perm); err != nil {
+			return fmt.Errorf("could not copy %v to %v: %v", name, to, err)
+		}
+		return nil
+	})
+}
+
+// Options of BuildSite.
+type SiteOptions struct {
+	Force         bool   // overwrite the contents of the output directory if it isn't empty
+	PageSize      int    // number of posts per index page, 0 disables pagination
+	Permalinks    bool   // generate a page per post at posts/<slug>/index.html
+	RemoveExpired bool   // don't generate permalink pages for expired posts, which are generated even without Permalinks
+	Archive       bool   // generate archive pages, implies Permalinks
+	Workers       int    // maximum number of posts rendered concurrently, defaults to the number of CPUs
+	NoFormat      bool   // write the generated HTML as is, which allows streaming the posts into index.html
+	Title         string // title of the website, available as {{.Site.Title}} in the page template
+	BaseURL       string // URL the website is served from, available as {{.Site.BaseURL}} in the page template
+
+	// Functions available in the page templates in addition to those of TemplateFuncs, usually the functions
+	// passed to WithTemplateFuncs for the post template. Functions with the same name replace those of TemplateFuncs.
+	Funcs template.FuncMap
+}
+
+// Builds the website for b into the directory outputDirectory on disk. source contains the page templates
+// (*.html.tmpl), layouts and partials (see PartialPatterns) and the static files of the website, and can be any
+// fs.FS, e.g. an os.DirFS, an embed.FS or a fstest.MapFS. Posts are rendered concurrently, the build stops when
+// ctx is cancelled. To parse the layouts and partials together with the post template and resolve absURL against
+// the base URL of the website, create b with these options:
+//
+//	blog, err := microblog.NewBlogFS(fsys, "posts",
+//		microblog.WithPartials(source, microblog.PartialPatterns...),
+//		microblog.WithBaseURL(baseURL))
+//	...
+//	err = microblog.BuildSite(ctx, source, blog, "/path/to/output", microblog.SiteOptions{BaseURL: baseURL})
+func BuildSite(ctx context.Context, source fs.FS, b Blog, outputDirectory string, options SiteOptions) error {
+	if err := overwriteDirectoryContents(source, outputDirectory, options.Force); err != nil {
+		return err
+	}
+
+	// build index.html
+	main, pages, err := findPageTemplates(source)
+	if err != nil {
+		return err
+	}
+	funcs := pageFuncs(options)
+	t, err := parsePageTemplate(source, main, "index.html", funcs)
+	if err != nil {
+		return err
+	}
+	tmpl := &pageTemplate{Template: t, format: !options.NoFormat, site: newSite(b, options, time.Now()), funcs: funcs}
+	r := postRenderer{ctx: ctx, opts: RenderOptions{Workers: options.Workers}}
+	if err := writeIndexPages(r, tmpl, outputDirectory, b, options.PageSize); err != nil {
+		return err
+	}
+
+	// all other pages, e.g. about/index.html
+	if err := writeSourcePages(r, tmpl, source, pages, outputDirectory, b); err != nil {
+		return err
+	}
+
+	// every section gets its own listing, e.g. notes/index.html
+	for _, section := range b.Sections() {
+		sectionPage, err := r.listing(tmpl, b.Section(section).GetBlogPosts(), newPagination("/", 1, 1))
+		if err != nil {
+			return fmt.Errorf("error when trying to render html for section %v: %w", section, err)
+		}
+		outputFp := filepath.Join(outputDirectory, filepath.FromSlash(section), "index.html")
+		if err := writePage(tmpl, outputFp, sectionPage); err != nil {
+			return err
+		}
+	}
+
+	// expired posts keep their permalink page with an archived notice unless they are removed, even if
+	// there are no permalink pages for the other posts
+	var permalinkPosts []BlogPost
+	if options.Permalinks || options.Archive {
+		permalinkPosts = b.GetBlogPosts()
+	}
+	if !options.RemoveExpired {
+		permalinkPosts = append(slices.Clip(permalinkPosts), b.Archived()...)
+	}
+	if err := writePermalinkPages(r, tmpl, outputDirectory, permalinkPosts); err != nil {
+		return err
+	}
+
+	if options.Archive {
+		if err := writeArchivePages(tmpl, source, outputDirectory, b); err != nil {
+			return err
+		}
+	}
+
+	// series overview pages, e.g. series/getting-started/index.html
+	for _, series := range b.Series() {
+		seriesPage, err := r.listing(tmpl, series.Posts, newPagination("/", 1, 1))
+		if err != nil {
+			return fmt.Errorf("error when trying to render html for series %v: %w", series.Name, err)
+		}
+		outputFp := filepath.Join(outputDirectory, "series", series.Slug, "index.html")
+		if err := writePage(tmpl, outputFp, seriesPage); err != nil {
+			return err
+		}
+	}
+
+	// taxonomy pages, e.g. tags/index.html and tags/go/index.html
+	if err := writeTaxonomyPages(r, tmpl, outputDirectory, TaxonomyTags, b.Tags(), b.PostsByTag); err != nil {
+		return err
+	}
+	if err := writeTaxonomyPages(r, tmpl, outputDirectory, TaxonomyCategories, b.Categories(), b.PostsByCategory); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Writes index.html and, if pageSize is greater than 0 and there are more posts than fit on one page,
+// the subsequent pages page/2/index.html, page/3/index.html, ...
+func writeIndexPages(r postRenderer, tmpl *pageTemplate, outputDirectory string, b Blog, pageSize int) error {
+	if pageSize <= 0 && !tmpl.format {
+		return writeStreamedPage(r, tmpl, filepath.Join(outputDirectory, "index.html"), b)
+	}
+	posts := b.GetBlogPosts()
+	if pageSize <= 0 {
+		pageSize = max(1, len(posts))
+	}
+	total := max(1, (len(posts)+pageSize-1)/pageSize)
+	for current := 1; current <= total; current++ {
+		p, err := r.listing(tmpl, posts[(current-1)*pageSize:min(current*pageSize, len(posts))], newPagination("/", current, total))
+		if err != nil {
+			return fmt.Errorf("error when trying to render html: %w", err)
+		}
+		outputFp := filepath.Join(outputDirectory, "index.html")
+		if current > 1 {
+			outputFp = filepath.Join(outputDirectory, "page", fmt.Sprint(current), "index.html")
+		}
+		if err := writePage(tmpl, outputFp, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes the page of every template in names to the path of the template in outputDirectory without the .tmpl
+// extension, e.g. about/index.html.tmpl to about/index.html. The pages list all posts of the blog, like index.html
+// without pagination.
+func writeSourcePages(r postRenderer, tmpl *pageTemplate, source fs.FS, names []string, outputDirectory string, b Blog) error {
+	if len(names) == 0 {
+		return nil
+	}
+	p, err := r.listing(tmpl, b.GetBlogPosts(), newPagination("/", 1, 1))
+	if err != nil {
+		return fmt.Errorf("error when trying to render html: %w", err)
+	}
+	for _, name := range names {
+		outputName := strings.TrimSuffix(name, ".tmpl")
+		t, err := parsePageTemplate(source, name, outputName, tmpl.funcs)
+		if err != nil {
+			return err
+		}
+		outputFp := filepath.Join(outputDirectory, filepath.FromSlash(outputName))
+		if err := writePage(&pageTemplate{Template: t, format: tmpl.format, site: tmpl.site, funcs: tmpl.funcs}, outputFp, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes a page for every post to posts/<slug>/index.html.
+func writePermalinkPages(r postRenderer, tmpl *pageTemplate, outputDirectory string, posts []BlogPost) error {
+	slugs := make(map[string]string, len(posts))
+	for _, p := range posts {
+		if other, ok := slugs[p.GetSlug()]; ok {
+			return fmt.Errorf("posts %v and %v have the same slug %v", other, p.GetName(), p.GetSlug())
+		}
+		slugs[p.GetSlug()] = p.GetName()
+	}
+	rendered, err := RenderEach(r.ctx, posts, r.opts)
+	if err != nil {
+		return err
+	}
+	for i, p := range posts {
+		outputFp := filepath.Join(outputDirectory, "posts", filepath.FromSlash(p.GetSlug()), "index.html")
+		if err := writePage(tmpl, outputFp, tmpl.listing([]BlogPost{p}, rendered[i:i+1], newPagination("/", 1, 1))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Renders the posts of the pages of a build concurrently, see RenderEach.
+type postRenderer struct {
+	ctx  context.Context
+	opts RenderOptions
+}
+
+// Renders the given posts as HTML and returns the page of tmpl listing them.
+func (r postRenderer) listing(tmpl *pageTemplate, posts []BlogPost, pagination pagination) (page, error) {
+	rendered, err := RenderEach(r.ctx, posts, r.opts)
+	if err != nil {
+		return page{}, err
+	}
+	return tmpl.listing(posts, rendered, pagination), nil
+}
+
+// Executes the page template with the given data and writes the HTML to outputFp, formatted unless disabled
+// for the template. Parent directories of outputFp are created if necessary.
+func writePage(tmpl *pageTemplate, outputFp string, data any) error {
+	if err := os.MkdirAll(filepath.Dir(outputFp), 0755); err != nil {
+		return fmt.Errorf("could not create directory %v: %v", filepath.Dir(outputFp), err)
+	}
+	outputFile, err := os.Create(outputFp)
+	if err != nil {
+		return fmt.Errorf("could not open output file %v: %v", outputFp, err)
+	}
+	defer outputFile.Close()
+
+	var buf bytes.Buffer
+	if err := tmpl.execute(&buf, data); err != nil {
+		return fmt.Errorf("could not generate output: %v", err)
+	}
+	html := buf.Bytes()
+	if tmpl.format {
+		html = gohtml.FormatBytes(html)
+	}
+	if _, err := outputFile.Write(html); err != nil {
+		return fmt.Errorf("could not write html to %v: %v", outputFp, err)
+	}
+	return nil
+}
+
+// Writes the page listing all posts of blog to outputFp like writePage, but streams the posts into the file in
+// order instead of rendering them all into memory first, see writeEach. This requires the template to insert the posts
+// exactly once, otherwise the page is written with writePage. The HTML is never formatted.
+func writeStreamedPage(r postRenderer, tmpl *pageTemplate, outputFp string, b Blog) error {
+	// execute the template around a placeholder for the posts, the template itself is small
+	const placeholder = "\x00microblog-gen:posts\x00"
+	var buf bytes.Buffer
+	placeholderPage := tmpl.listing(b.GetBlogPosts(), nil, newPagination("/", 1, 1))
+	placeholderPage.Content = placeholder
+	if err := tmpl.execute(&buf, placeholderPage); err != nil {
+		return fmt.Errorf("could not generate output: %v", err)
+	}
+	before, after, ok := bytes.Cut(buf.Bytes(), []byte(placeholder))
+	if !ok || bytes.Contains(after, []byte(placeholder)) {
+		p, err := r.listing(tmpl, b.GetBlogPosts(), newPagination("/", 1, 1))
+		if err != nil {
+			return fmt.Errorf("error when trying to render html: %w", err)
+		}
+		return writePage(tmpl, outputFp, p)
+	}
+
+	// write to a temporary file that replaces outputFp only once all posts have been written, so a failed or
+	// cancelled build doesn't leave a truncated page behind
+	if err := os.MkdirAll(filepath.Dir(outputFp), 0755); err != nil {
+		return fmt.Errorf("could not create directory %v: %v", filepath.Dir(outputFp), err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(outputFp), "."+filepath.Base(outputFp)+".*")
+	if err != nil {
+		return fmt.Errorf("could not open output file %v: %v", outputFp, err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+	w := bufio.NewWriter(tmpFile)
+	w.Write(before)
+	if err := writeEach(r.ctx, w, b.GetBlogPosts(), r.opts); err != nil {
+		return fmt.Errorf("error when trying to render html: %w", err)
+	}
+	w.Write(after)
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("could not write html to %v: %v", outputFp, err)
+	}
+	if err := tmpFile.Chmod(0644); err != nil {
+		return fmt.Errorf("could not write html to %v: %v", outputFp, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("could not write html to %v: %v", outputFp, err)
+	}
+	if err := os.Rename(tmpFile.Name(), outputFp); err != nil {
+		return fmt.Errorf("could not write html to %v: %v", outputFp, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package microblog
+
+import (
+	"context"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBuildSite(t *testing.T) {
+	out := t.TempDir()
+	fsys := fstest.MapFS{
+		"site/index.html.tmpl":  {Data: []byte(`<h1>{{shout "blog"}}</h1><div class="blog">{{.Content}}</div>`)},
+		"site/css/index.css":    {Data: []byte(`.foo { display: flex; }`), Mode: 0600},
+		"site/bin/deploy.sh":    {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+		"site/archive.tmpl":     {Data: []byte(`{{range .Years}}{{.Year}}{{end}} {{shout "archive"}}`)},
+		"posts/a.md":            {Data: []byte("---\ndate: 2024-03-01\n---\n## Title\nhey [google](https://google.com).")},
+		"posts/notes/b.md":      {Data: []byte("---\ndate: 2023-03-01\n---\n## Note\nhello")},
+		"posts/notes/order.txt": {Data: []byte("ignored, manifests are only read from the blog directory")},
+	}
+	source, err := fs.Sub(fsys, "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// custom functions are available in the post template and the page templates
+	funcs := template.FuncMap{"shout": strings.ToUpper}
+	blog, err := NewBlogFS(fsys, "posts", WithSections(), WithPartials(source, PartialPatterns...),
+		WithTemplateFuncs(funcs), WithTemplateString(`<div class="blog-post">{{shout .GetTitle}}{{.Content}}</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := BuildSite(context.Background(), source, blog, out, SiteOptions{Archive: true, Funcs: funcs}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fp := range []string{"index.html", "css/index.css", "notes/index.html", "posts/a/index.html", "posts/notes/b/index.html", "archive/index.html", "archive/2024/index.html"} {
+		if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(fp))); err != nil {
+			t.Errorf("expected %v in the output directory: %v", fp, err)
+		}
+	}
+	for _, fp := range []string{"index.html.tmpl", "archive.tmpl"} {
+		if _, err := os.Stat(filepath.Join(out, fp)); err == nil {
+			t.Errorf("expected template %v not to be copied to the output directory", fp)
+		}
+	}
+	// static files keep their permissions
+	for fp, perm := range map[string]fs.FileMode{"css/index.css": 0600, "bin/deploy.sh": 0755} {
+		info, err := os.Stat(filepath.Join(out, filepath.FromSlash(fp)))
+		if err != nil {
+			t.Error(err)
+		} else if info.Mode().Perm() != perm {
+			t.Errorf("expected %v to be copied with permissions %v, got %v", fp, perm, info.Mode().Perm())
+		}
+	}
+	indexHtml, err := os.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(indexHtml), `<a href="https://google.com" target="_blank">`) {
+		t.Errorf("expected index.html to contain link to google, got %s", indexHtml)
+	}
+	if !strings.Contains(string(indexHtml), "BLOG") || !strings.Contains(string(indexHtml), "TITLE") {
+		t.Errorf("expected the custom function in the page and post templates, got %s", indexHtml)
+	}
+	archiveHtml, err := os.ReadFile(filepath.Join(out, "archive", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(archiveHtml), "20242023") || !strings.Contains(string(archiveHtml), "ARCHIVE") {
+		t.Errorf("expected archive to be rendered with archive.tmpl, got %s", archiveHtml)
+	}
+}
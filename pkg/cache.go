@@ -0,0 +1,107 @@
+package microblog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// Version of the rendering, part of the key of the render cache. Increment it whenever a change to the rendering
+// changes the HTML of existing posts, so cached HTML isn't reused.
+const renderVersion = 2
+
+// Cache the HTML written by BlogPost.WriteHtml in the database used for publication tracking (see
+// WithRegistryDirectory), so unchanged posts are not rendered again, e.g. on the next build of a blog. The cache
+// is keyed by a hash of the Markdown of the post, the template, the renderer options, the publication date and
+// the posts it links to (see BlogPost.Series and BlogPost.Related). Changing any of them renders the post again.
+func WithRenderCache() PostOption {
+	return func(b *blogPost) error {
+		b.renderCache = true
+		return nil
+	}
+}
+
+// Returns the key of the HTML of the post with the Markdown file and the publication date dtPosted in the
+// render cache.
+func (p *blogPost) renderKey(file []byte, dtPosted time.Time) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v\x00%v\x00%v\x00%v\x00", renderVersion, htmlFlags, p.template.hash, p.name)
+	fmt.Fprintf(h, "%v\x00%v\x00%v\x00", dtPosted.Format(time.DateOnly), p.IsScheduled(), p.IsExpired())
+	h.Write(file)
+
+	// the template has access to the posts linked to by this post, e.g. to their titles and URLs
+	var linked []*blogPost
+	if p.series != nil {
+		for _, s := range p.series.Posts {
+			linked = append(linked, s.(*blogPost))
+		}
+	}
+	linked = append(linked, p.related...)
+	for _, l := range linked {
+		content, err := fs.ReadFile(l.fsys, l.name)
+		if err != nil {
+			return "", fmt.Errorf("could not read file %v: %v", l.FilePath, err)
+		}
+		fmt.Fprintf(h, "\x00%v\x00", l.name)
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Writes the HTML of the post from the render cache, or renders it and stores it in the cache.
+func (p *blogPost) writeCachedHtml(w io.Writer) error {
+	file, err := fs.ReadFile(p.fsys, p.name)
+	if err != nil {
+		return fmt.Errorf("could not read file %v: %v", p.FilePath, err)
+	}
+	backend, err := p.registry()
+	if err != nil {
+		return err
+	}
+
+	// a post that hasn't been published before is never cached, rendering records its publication date
+	dtPosted, err := p.GetPublicationDate()
+	if err != nil {
+		return err
+	}
+	if dtPosted == nil && !(p.tracksPublication() && p.IsPublished()) {
+		today := time.Now()
+		dtPosted = &today
+	}
+	if dtPosted != nil {
+		key, err := p.renderKey(file, *dtPosted)
+		if err != nil {
+			return err
+		}
+		cached, err := backend.GetCachedHtml(p, key)
+		if err != nil {
+			return err
+		}
+		if cached != nil {
+			_, err := w.Write(cached)
+			return err
+		}
+	}
+
+	rendered, err := p.render(file)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := p.template.tmpl.Execute(&buf, rendered); err != nil {
+		return templateError(p.FilePath, err)
+	}
+	key, err := p.renderKey(file, rendered.Published)
+	if err != nil {
+		return err
+	}
+	if err := backend.SetCachedHtml(p, key, buf.Bytes()); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,89 @@
+package microblog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlogRenderCache(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("---\nseries: Intro\n---\n## First\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("---\nseries: Intro\n---\n## Second\nhello"), 0644)
+	os.WriteFile(filepath.Join(d, "c.md"), []byte("## Third\nhi"), 0644)
+
+	template := WithTemplateString(`<article>{{.Heading}}{{with .Next}} next: {{.GetTitle}}{{end}}</article>`)
+	render := func() string {
+		t.Helper()
+		blog, err := NewBlog(d, template, WithRenderCache(), WithPublicationTracking())
+		if err != nil {
+			t.Fatal(err)
+		}
+		html, err := blog.RenderPosts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(html)
+	}
+	first := render()
+	if !strings.Contains(first, "next: Second") {
+		t.Fatalf("expected link to the next post in the series, got %v", first)
+	}
+	if second := render(); second != first {
+		t.Errorf("expected cached html %v to match %v", second, first)
+	}
+
+	// mark the cached html, so it's visible whether a post is rendered again
+	registry, err := acquireRegistry(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.DB.Exec("UPDATE render_cache SET html = CAST('<cached>' || name || '</cached>' AS BLOB);"); err != nil {
+		t.Fatal(err)
+	}
+	if html := render(); html != "<cached>a.md</cached><cached>b.md</cached><cached>c.md</cached>" {
+		t.Errorf("expected all posts to be served from the cache, got %v", html)
+	}
+
+	// a.md links to b.md, so changing b.md renders both of them again
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("---\nseries: Intro\n---\n## Changed\nhello"), 0644)
+	html := render()
+	if !strings.Contains(html, "next: Changed") || strings.Contains(html, "<cached>b.md") {
+		t.Errorf("expected a.md and b.md to be rendered again, got %v", html)
+	}
+	if !strings.HasSuffix(html, "<cached>c.md</cached>") {
+		t.Errorf("expected c.md to be served from the cache, got %v", html)
+	}
+
+	// a different template renders all posts again
+	template = WithTemplateString(`<section>{{.Heading}}</section>`)
+	if html := render(); strings.Contains(html, "<cached>") || strings.Count(html, "<section>") != 3 {
+		t.Errorf("expected all posts to be rendered with the new template, got %v", html)
+	}
+}
+
+func TestBlogRenderCacheBaseURL(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("## First\nhey"), 0644)
+
+	render := func(baseURL string) string {
+		t.Helper()
+		blog, err := NewBlog(d, WithTemplateString(`<a href="{{absURL .GetURL}}">{{.Heading}}</a>`), WithBaseURL(baseURL), WithRenderCache())
+		if err != nil {
+			t.Fatal(err)
+		}
+		html, err := blog.RenderPosts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(html)
+	}
+	if html := render("https://example.com"); !strings.Contains(html, `href="https://example.com/posts/a/"`) {
+		t.Fatalf("expected absolute URL, got %v", html)
+	}
+	// a different base URL renders the post again instead of serving the links to the old one
+	if html := render("https://example.org/blog/"); !strings.Contains(html, `href="https://example.org/blog/posts/a/"`) {
+		t.Errorf("expected absolute URL with the new base URL, got %v", html)
+	}
+}
@@ -0,0 +1,172 @@
+package microblog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Checks all posts in directory and returns every problem found, joined with errors.Join, or nil if there are
+// none. Unlike NewBlog, which stops at the first problem, Check reports all posts with invalid front matter or
+// an invalid structure (see PostError), posts for which the post template can't be executed, posts with the same
+// slug and a manifest that doesn't match the posts. Drafts and expired posts are checked as well. Nothing is
+// written, in particular no publication dates are recorded. Check accepts the same options as NewBlog.
+//
+//	if err := microblog.Check("/path/to/md/directory", microblog.WithTemplateFile("/path/to/html/template")); err != nil {
+//		fmt.Println(err) // one problem per line
+//	}
+func Check(directory string, options ...Option) error {
+	i, err := os.Stat(directory)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("directory %v does not exist", directory)
+		}
+		return fmt.Errorf("could not acquire file info for %v: %v", directory, err)
+	}
+	if !i.IsDir() {
+		return fmt.Errorf("%v must be a directory", directory)
+	}
+	return check(os.DirFS(directory), &blog{Directory: directory, join: filepath.Join}, options...)
+}
+
+// Checks the posts in the directory dir of the file system fsys, see Check and NewBlogFS.
+func CheckFS(fsys fs.FS, dir string, options ...Option) error {
+	i, err := fs.Stat(fsys, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("directory %v does not exist", dir)
+		}
+		return fmt.Errorf("could not acquire file info for %v: %v", dir, err)
+	}
+	if !i.IsDir() {
+		return fmt.Errorf("%v must be a directory", dir)
+	}
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("could not open directory %v: %v", dir, err)
+	}
+	return check(sub, &blog{Directory: dir, join: path.Join}, options...)
+}
+
+// Checks the posts of the blog b in fsys, which is rooted at the blog directory, see Check.
+func check(fsys fs.FS, b *blog, options ...Option) error {
+	var problems []error
+	for _, o := range options {
+		if err := o.applyToBlog(b); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	// post options such as WithTemplateFile fail the same way for every post, so they are reported only once
+	// and the posts are checked without them
+	var postOptions []PostOption
+	for _, o := range b.postOptions {
+		if err := o(&blogPost{}); err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		postOptions = append(postOptions, o)
+	}
+
+	markdownFiles, err := findMarkdownFiles(fsys, b.recursive)
+	if err != nil {
+		return errors.Join(append(problems, fmt.Errorf("failed to search for markdown files in %v: %v", b.Directory, err))...)
+	}
+	if len(markdownFiles) == 0 {
+		problems = append(problems, errors.New("there must be at least .md file in the directory"))
+	}
+	if err := checkSections(markdownFiles); err != nil {
+		problems = append(problems, err)
+	}
+
+	posts := make([]*blogPost, 0, len(markdownFiles))
+	slugs := make(map[string]string, len(markdownFiles))
+	reported := make(map[string]bool) // problems that aren't specific to a post, e.g. with partials
+	for _, md := range markdownFiles {
+		fp := b.join(b.Directory, md)
+		post, err := newBlogPost(fsys, md, fp, postOptions...)
+		if err != nil {
+			var postErr *PostError
+			if errors.As(err, &postErr) || !reported[err.Error()] {
+				problems = append(problems, err)
+				reported[err.Error()] = true
+			}
+			posts = append(posts, &blogPost{name: md}) // still counts as listed in the manifest
+			continue
+		}
+		posts = append(posts, post)
+		if err := post.check(); err != nil {
+			problems = append(problems, err)
+		}
+		if other, ok := slugs[post.GetSlug()]; ok {
+			problems = append(problems, &PostError{Path: fp, Kind: KindDuplicateSlug, Err: fmt.Errorf("slug %v is already used by %v", post.GetSlug(), other)})
+		} else {
+			slugs[post.GetSlug()] = fp
+		}
+	}
+
+	if b.manifest == nil {
+		fp, entries, err := readManifest(fsys, b.Directory, b.join)
+		if err != nil {
+			problems = append(problems, err)
+		} else if fp != "" {
+			b.manifest, b.manifestFile, b.sortOrder = entries, fp, SortByManifest
+			if b.manifest == nil {
+				b.manifest = []string{}
+			}
+		}
+	}
+	if b.sortOrder == SortByManifest && b.manifest != nil {
+		if err := validateManifest(b.manifest, posts); err != nil {
+			err.File = b.manifestFile
+			problems = append(problems, err)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// Renders the post and executes its template without recording a publication date or writing the output,
+// see Check.
+func (p *blogPost) check() error {
+	file, err := fs.ReadFile(p.fsys, p.name)
+	if err != nil {
+		return fmt.Errorf("could not read file %v: %v", p.FilePath, err)
+	}
+	rendered, err := p.renderMarkdown(file)
+	if err != nil {
+		return err
+	}
+	rendered.Published = time.Now()
+	rendered.DtPosted = rendered.Published.Format(time.DateOnly)
+	if err := p.template.tmpl.Execute(io.Discard, rendered); err != nil {
+		return templateError(p.FilePath, err)
+	}
+	return nil
+}
+
+// Parses the page templates (*.html.tmpl) in source, the source directory of BuildSite, with the functions of
+// options (see SiteOptions.Funcs) and executes them with placeholder data. Returns all problems joined with
+// errors.Join, or nil if there are none.
+func CheckPageTemplates(source fs.FS, options SiteOptions) error {
+	main, pages, err := findPageTemplates(source)
+	if err != nil {
+		return err
+	}
+	var problems []error
+	for _, name := range append([]string{main}, pages...) {
+		tmpl, err := parsePageTemplate(source, name, name, pageFuncs(options))
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		placeholder := &pageTemplate{Template: tmpl, site: &site{}}
+		if err := placeholder.execute(io.Discard, placeholder.singlePage("")); err != nil {
+			problems = append(problems, fmt.Errorf("could not generate output: %v", err))
+		}
+	}
+	return errors.Join(problems...)
+}
@@ -0,0 +1,91 @@
+package microblog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheck(t *testing.T) {
+	d := t.TempDir()
+	for name, content := range map[string]string{
+		"a.md": "## Title\nhey",
+		"b.md": "## Title\nhey\n\n## Another title\nhi",
+		"c.md": "hey",
+		"d.md": "---\ndate: [\n---\n## Title\nhey",
+		"e.md": "---\nslug: a\n---\n## Title\nhey",
+		"f.md": "---\ndraft: true\n---\n## Title",
+	} {
+		os.WriteFile(filepath.Join(d, name), []byte(content), 0644)
+	}
+
+	err := Check(d)
+	if err == nil {
+		t.Fatal("expected problems")
+	}
+	kinds := make(map[string]ErrorKind)
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var postErr *PostError
+		if !errors.As(e, &postErr) {
+			t.Fatalf("expected *PostError, got %v", e)
+		}
+		kinds[filepath.Base(postErr.Path)] = postErr.Kind
+	}
+	expected := map[string]ErrorKind{
+		"b.md": KindMultipleHeadings,
+		"c.md": KindNoHeading,
+		"d.md": KindFrontMatter,
+		"e.md": KindDuplicateSlug,
+		"f.md": KindNoParagraphs, // drafts are checked as well
+	}
+	for name, kind := range expected {
+		if kinds[name] != kind {
+			t.Errorf("expected %v for %v, got %q", kind, name, kinds[name])
+		}
+	}
+	if len(kinds) != len(expected) {
+		t.Errorf("expected %v problems, got %v", len(expected), err)
+	}
+	if n := len(strings.Split(err.Error(), "\n")); n != len(expected) {
+		t.Errorf("expected one problem per line, got %v lines", n)
+	}
+
+	// nothing is recorded
+	if _, err := os.Stat(filepath.Join(d, "blog.sqlite")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no registry to be created, got %v", err)
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"posts/a.md": {Data: []byte("## Title\nhey")},
+		"posts/b.md": {Data: []byte("## Title\nhi")},
+	}
+	if err := CheckFS(fsys, "posts", WithPublicationTracking()); err != nil {
+		t.Errorf("expected no problems, got %v", err)
+	}
+
+	err := CheckFS(fsys, "posts", WithTemplateString("<h2>{{index .Tags 1}}</h2>"))
+	var postErr *PostError
+	if !errors.As(err, &postErr) || postErr.Kind != KindTemplate {
+		t.Fatalf("expected template error, got %v", err)
+	}
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 2 {
+		t.Errorf("expected a problem per post, got %v", err)
+	}
+
+	// a template that can't be parsed is reported once
+	err = CheckFS(fsys, "posts", WithTemplateString("{{.Heading"), WithManifest("a.md", "c.md"))
+	problems := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(problems) != 2 || !strings.Contains(problems[0].Error(), "could not open template") {
+		t.Fatalf("expected template and manifest error, got %v", err)
+	}
+	var manifestErr *ManifestError
+	if !errors.As(problems[1], &manifestErr) || !slices.Equal(manifestErr.Missing, []string{"b.md"}) || !slices.Equal(manifestErr.Unknown, []string{"c.md"}) {
+		t.Errorf("expected manifest error, got %v", problems[1])
+	}
+}
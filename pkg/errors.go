@@ -0,0 +1,114 @@
+package microblog
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Kind of problem with a post, see PostError.
+type ErrorKind string
+
+const (
+	KindFrontMatter      ErrorKind = "front matter"      // front matter that isn't valid YAML or doesn't match Metadata
+	KindNoHeading        ErrorKind = "no heading"        // post without heading
+	KindMultipleHeadings ErrorKind = "multiple headings" // post with more than one heading
+	KindNoParagraphs     ErrorKind = "no paragraphs"     // post without text below the heading
+	KindUnexpectedNode   ErrorKind = "unexpected node"   // Markdown other than headings and paragraphs, e.g. a list
+	KindTemplate         ErrorKind = "template"          // post template that can't be executed for the post
+	KindDuplicateSlug    ErrorKind = "duplicate slug"    // post with the same slug as another post, see Check
+)
+
+// Describes a problem with the Markdown file of a post, returned (possibly wrapped) by NewBlog, NewBlogPost and
+// the render methods. Use errors.As to access it:
+//
+//	var postErr *microblog.PostError
+//	if errors.As(err, &postErr) && postErr.Kind == microblog.KindNoHeading {
+//		...
+//	}
+type PostError struct {
+	Path   string    // file path of the post, see BlogPost.GetFilePath
+	Line   int       // line of the problem in the file, starting at 1, or 0 if unknown
+	Column int       // column of the problem in the line, starting at 1, or 0 if unknown
+	Kind   ErrorKind // kind of the problem
+	Err    error     // description of the problem
+}
+
+// Returns the error in the form path:line:column: description, omitting an unknown line or column.
+func (e *PostError) Error() string {
+	position := e.Path
+	if e.Line > 0 {
+		position += fmt.Sprintf(":%v", e.Line)
+		if e.Column > 0 {
+			position += fmt.Sprintf(":%v", e.Column)
+		}
+	}
+	return fmt.Sprintf("%v: %v", position, e.Err)
+}
+
+func (e *PostError) Unwrap() error {
+	return e.Err
+}
+
+// Returns the error of the post template failing to execute for the post at path, see KindTemplate.
+func templateError(path string, err error) *PostError {
+	return &PostError{Path: path, Kind: KindTemplate, Err: fmt.Errorf("could not generate output: %v", err)}
+}
+
+var yamlLine = regexp.MustCompile(`line (\d+)`)
+
+// Returns the line of content in which the YAML error err of the front matter occurred, or 0 if unknown.
+func frontMatterErrorLine(content []byte, err error) int {
+	m := yamlLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(m[1])
+	leading := len(content) - len(bytes.TrimLeft(content, "\r\n\t "))
+	delimiter := bytes.Count(content[:leading], []byte("\n")) + 1 // line of the opening ---
+	return delimiter + line
+}
+
+var (
+	atxHeading    = regexp.MustCompile(`^ {0,3}#{1,6}(\s|$)`)
+	setextHeading = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	codeFence     = regexp.MustCompile("^ {0,3}(```|~~~)")
+)
+
+// Returns the line and column, starting at 1, of the first character of every top-level block of the Markdown
+// body, which starts at line offset+1 of the file. gomarkdown doesn't record source positions, so the blocks are
+// determined by a simplified scan that agrees with the parser for headings, paragraphs and code blocks, which is
+// what is needed to locate problems with posts (see BlogPost.WriteHtml). Positions of blocks after other
+// constructs, e.g. lists with blank lines between their items, may be off.
+func blockPositions(body []byte, offset int) [][2]int {
+	var positions [][2]int
+	inBlock, inFence := false, false
+	for i, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		trimmed := bytes.TrimLeft(line, " \t")
+		switch {
+		case inFence:
+			if codeFence.Match(line) {
+				inFence, inBlock = false, false
+			}
+			continue
+		case len(trimmed) == 0:
+			inBlock = false
+			continue
+		case inBlock && setextHeading.Match(line): // underline of the heading above
+			inBlock = false
+			continue
+		case atxHeading.Match(line):
+			inBlock = false // a heading is a block of its own, even without blank lines around it
+		case inBlock:
+			continue
+		default:
+			inBlock = true
+		}
+		positions = append(positions, [2]int{offset + i + 1, len(line) - len(trimmed) + 1})
+		inFence = codeFence.Match(line)
+		inBlock = inBlock || inFence
+	}
+	return positions
+}
@@ -0,0 +1,87 @@
+package microblog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPostError(t *testing.T) {
+	d := t.TempDir()
+
+	for content, expected := range map[string]PostError{
+		"hey":                                   {Line: 1, Column: 1, Kind: KindNoHeading},
+		"\n\n## Title":                          {Line: 3, Column: 1, Kind: KindNoParagraphs},
+		"## Title\nhey\n\n## Another title\nhi": {Line: 4, Column: 1, Kind: KindMultipleHeadings},
+		"## Title\nhey\n\n- one\n- two":         {Line: 4, Column: 1, Kind: KindUnexpectedNode},
+		"## Title\n```\ncode\n\nmore\n```\n\n  > hi":                       {Line: 2, Column: 1, Kind: KindUnexpectedNode},
+		"---\ndate: 2024-01-01\n---\n## Title\nhey\nmore text\n\n## Third": {Line: 8, Column: 1, Kind: KindMultipleHeadings},
+	} {
+		fp := filepath.Join(d, "post.md")
+		os.WriteFile(fp, []byte(content), 0644)
+		post, err := NewBlogPost(fp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = post.Render()
+		var postErr *PostError
+		if !errors.As(err, &postErr) {
+			t.Errorf("expected *PostError for %q, got %v", content, err)
+			continue
+		}
+		if postErr.Path != fp || postErr.Line != expected.Line || postErr.Column != expected.Column || postErr.Kind != expected.Kind {
+			t.Errorf("expected %v at %v:%v for %q, got %v at %v:%v (%v)", expected.Kind, expected.Line, expected.Column, content, postErr.Kind, postErr.Line, postErr.Column, postErr)
+		}
+	}
+}
+
+func TestPostErrorFrontMatter(t *testing.T) {
+	d := t.TempDir()
+	fp := filepath.Join(d, "post.md")
+	os.WriteFile(fp, []byte("\n---\ndate: 2024-01-01\nweight: heavy\n---\n## Title\nhey"), 0644)
+
+	_, err := NewBlog(d)
+	var postErr *PostError
+	if !errors.As(err, &postErr) {
+		t.Fatalf("expected *PostError, got %v", err)
+	}
+	if postErr.Kind != KindFrontMatter || postErr.Line != 4 {
+		t.Errorf("expected front matter error in line 4, got %v in line %v", postErr.Kind, postErr.Line)
+	}
+	if postErr.Error() != fp+":4: "+postErr.Err.Error() {
+		t.Errorf("expected error to start with the position, got %v", postErr)
+	}
+}
+
+func TestPostErrorTemplate(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "post.md"), []byte("## Title\nhey"), 0644)
+
+	// the same error whether the post is rendered, served from the render cache or checked
+	template := WithTemplateString(`{{index .Tags 0}}`)
+	for name, render := range map[string]func() error{
+		"render": func() error {
+			blog, err := NewBlog(d, template)
+			if err != nil {
+				return err
+			}
+			_, err = blog.RenderPosts()
+			return err
+		},
+		"cache": func() error {
+			blog, err := NewBlog(d, template, WithRenderCache(), WithRegistryDirectory(t.TempDir()))
+			if err != nil {
+				return err
+			}
+			_, err = blog.RenderPosts()
+			return err
+		},
+		"check": func() error { return Check(d, template) },
+	} {
+		var postErr *PostError
+		if err := render(); !errors.As(err, &postErr) || postErr.Kind != KindTemplate || postErr.Path != filepath.Join(d, "post.md") {
+			t.Errorf("%v: expected *PostError of kind %v, got %v", name, KindTemplate, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package microblog
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const frontMatterDelimiter = "---"
+
+// Metadata of a blog post, defined in an optional YAML front matter block at the very top of the Markdown file:
+//
+//	---
+//	date: 2024-01-01
+//	weight: 10
+//	tags: [go, web]
+//	---
+//	## Hello World!
+type Metadata struct {
+	// Publication date of the post. Takes precedence over the date recorded by publication tracking.
+	Date *time.Time `yaml:"date"`
+	// Position of the post when sorting by weight (lower weights come first).
+	Weight int `yaml:"weight"`
+	// Tags of the post, see Blog.PostsByTag.
+	Tags []string `yaml:"tags"`
+	// Categories of the post, see Blog.PostsByCategory.
+	Categories []string `yaml:"categories"`
+	// Drafts are excluded from a Blog unless WithDrafts is used, and never get a publication date.
+	Draft bool `yaml:"draft"`
+	// Scheduled publication date. Until then, the post is treated like a draft.
+	PublishAt *time.Time `yaml:"publishAt"`
+	// Date after which the post is archived, see Blog.Archived.
+	Expires *time.Time `yaml:"expires"`
+	// Name of the series the post belongs to, see BlogPost.Series.
+	SeriesName string `yaml:"series"`
+	// Position of the post within its series (starting at 1). Posts without position come last.
+	SeriesPosition int `yaml:"seriesPosition"`
+	// Identifier of the post in its URL, derived from the file name by default (see BlogPost.GetSlug). It is part of
+	// the path of the permalink page, so it must not contain /, \ or "..".
+	Slug string `yaml:"slug"`
+}
+
+// Returns a deep copy of m, which shares neither the slices nor the dates with m.
+func (m Metadata) clone() Metadata {
+	m.Tags = slices.Clone(m.Tags)
+	m.Categories = slices.Clone(m.Categories)
+	m.Date = cloneTime(m.Date)
+	m.PublishAt = cloneTime(m.PublishAt)
+	m.Expires = cloneTime(m.Expires)
+	return m
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// Splits the content of a Markdown file into its front matter (without delimiters) and the Markdown body.
+// If the file has no front matter, the returned front matter is nil and the body is the full content.
+func splitFrontMatter(content []byte) ([]byte, []byte) {
+	trimmed := bytes.TrimLeft(content, "\r\n\t ")
+	firstLine, rest, ok := bytes.Cut(trimmed, []byte("\n"))
+	if !ok || string(bytes.TrimSpace(firstLine)) != frontMatterDelimiter {
+		return nil, content
+	}
+	var matter []byte
+	for len(rest) > 0 {
+		line, remainder, _ := bytes.Cut(rest, []byte("\n"))
+		if string(bytes.TrimSpace(line)) == frontMatterDelimiter {
+			return matter, remainder
+		}
+		matter = append(matter, line...)
+		matter = append(matter, '\n')
+		rest = remainder
+	}
+	return nil, content // no closing delimiter, treat as plain markdown
+}
+
+// Parses the front matter of a Markdown file. Files without front matter yield empty Metadata.
+func parseFrontMatter(content []byte) (Metadata, error) {
+	var m Metadata
+	matter, _ := splitFrontMatter(content)
+	if matter == nil {
+		return m, nil
+	}
+	if err := yaml.Unmarshal(matter, &m); err != nil {
+		return m, fmt.Errorf("invalid front matter: %v", err)
+	}
+	if strings.ContainsAny(m.Slug, `/\`) || strings.Contains(m.Slug, "..") {
+		return m, fmt.Errorf(`invalid front matter: slug %q must not contain /, \ or ".."`, m.Slug)
+	}
+	return m, nil
+}
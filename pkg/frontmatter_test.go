@@ -0,0 +1,63 @@
+package microblog
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	content := []byte("---\ndate: 2024-01-02\nweight: 3\n---\n## Title\nhey")
+	matter, body := splitFrontMatter(content)
+	if string(matter) != "date: 2024-01-02\nweight: 3\n" {
+		t.Errorf("unexpected front matter %q", matter)
+	}
+	if string(body) != "## Title\nhey" {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	// no front matter
+	content = []byte("## Title\nhey")
+	matter, body = splitFrontMatter(content)
+	if matter != nil {
+		t.Errorf("expected no front matter, got %q", matter)
+	}
+	if string(body) != string(content) {
+		t.Errorf("expected body to be the full content, got %q", body)
+	}
+
+	// no closing delimiter
+	content = []byte("---\n## Title\nhey")
+	matter, body = splitFrontMatter(content)
+	if matter != nil || string(body) != string(content) {
+		t.Errorf("expected unterminated front matter to be treated as markdown, got %q and %q", matter, body)
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	m, err := parseFrontMatter([]byte("---\ndate: 2024-01-02\nweight: 3\n---\n## Title\nhey"))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if m.Date == nil || !m.Date.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected date 2024-01-02, got %v", m.Date)
+	}
+	if m.Weight != 3 {
+		t.Errorf("expected weight 3, got %v", m.Weight)
+	}
+
+	if _, err := parseFrontMatter([]byte("---\nweight: [\n---\n## Title\nhey")); err == nil {
+		t.Error("expected error for invalid front matter")
+	}
+
+	// the slug becomes part of the path of the permalink page
+	for _, slug := range []string{"../../etc", "a/b", `a\b`, ".."} {
+		if _, err := parseFrontMatter([]byte(fmt.Sprintf("---\nslug: '%v'\n---\n## Title\nhey", slug))); err == nil {
+			t.Errorf("expected error for slug %v", slug)
+		}
+	}
+	if m, err := parseFrontMatter([]byte("---\nslug: hello.world\n---\n## Title\nhey")); err != nil || m.Slug != "hello.world" {
+		t.Errorf("expected slug hello.world, got %q (%v)", m.Slug, err)
+	}
+}
@@ -0,0 +1,311 @@
+package microblog
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// Returns the functions available in post templates in addition to the predefined functions of html/template,
+// see WithTemplateFuncs to add further functions. The page templates of the CLI can use them as well. absURL
+// resolves paths against baseURL. markdownify returns template.HTML, which is inserted as is, and json returns
+// template.JS, which is inserted as is in scripts. The results of all other functions are escaped.
+//
+//	{{.Published | date "January 2, 2006"}}  formats a time.Time or *time.Time, see time.Time.Format
+//	{{truncate 80 .GetTitle}}               shortens text to 80 characters, adding an ellipsis
+//	{{excerpt 30 .Content}}                 the first 30 words of the text of HTML, adding an ellipsis
+//	{{markdownify "*hey*"}}                 renders Markdown as HTML, without <p> for a single paragraph
+//	{{slugify "Hello World"}}               converts text to a slug, e.g. hello-world
+//	{{absURL .GetURL}}                      prefixes a path with baseURL
+//	{{readingTime .Content}}                reading time of HTML in minutes, at 200 words per minute
+//	{{where .Posts "Section" "notes"}}      elements of a slice whose field or method equals (or contains) a value
+//	{{sortBy .Posts "Published" "desc"}}    copy of a slice sorted by a field or method, ascending unless "desc"
+//	{{first 3 .Posts}}                      the first elements of a slice
+//	<script>var tags = {{json .Tags}}</script>  encodes a value as JSON
+//
+// Fields and methods of where and sortBy can be nested, e.g. "Published.Year".
+func TemplateFuncs(baseURL string) template.FuncMap {
+	return template.FuncMap{
+		"date":        formatDate,
+		"truncate":    truncate,
+		"excerpt":     excerpt,
+		"markdownify": markdownify,
+		"slugify":     func(s any) string { return slugify(text(s)) },
+		"absURL":      func(p any) string { return absURL(baseURL, text(p)) },
+		"readingTime": readingTime,
+		"where":       where,
+		"sortBy":      sortBy,
+		"first":       first,
+		"json":        toJSON,
+	}
+}
+
+// Returns the text of a template argument, e.g. a string or a fmt.Stringer.
+func text(s any) string {
+	if s, ok := s.(string); ok {
+		return s
+	}
+	return fmt.Sprint(s)
+}
+
+func formatDate(layout string, t any) (string, error) {
+	switch t := t.(type) {
+	case time.Time:
+		return t.Format(layout), nil
+	case *time.Time:
+		if t == nil {
+			return "", nil
+		}
+		return t.Format(layout), nil
+	}
+	return "", fmt.Errorf("date expects a time.Time, got %T", t)
+}
+
+func truncate(n int, s any) string {
+	runes := []rune(text(s))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:max(n, 0)])) + "…"
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// Returns the words of the text of the HTML s.
+func words(s any) []string {
+	return strings.Fields(html.UnescapeString(htmlTag.ReplaceAllString(text(s), " ")))
+}
+
+func excerpt(n int, s any) string {
+	w := words(s)
+	if len(w) <= n {
+		return strings.Join(w, " ")
+	}
+	return strings.Join(w[:max(n, 0)], " ") + "…"
+}
+
+func markdownify(s any) template.HTML {
+	rendered := strings.TrimSpace(string(markdown.ToHTML([]byte(text(s)), parser.New(), newHtmlRenderer())))
+	inner, ok := strings.CutPrefix(rendered, "<p>")
+	if inner, ok2 := strings.CutSuffix(inner, "</p>"); ok && ok2 && !strings.Contains(inner, "<p>") {
+		return template.HTML(inner)
+	}
+	return template.HTML(rendered)
+}
+
+// Returns p prefixed with baseURL, unless baseURL is empty or p is an absolute URL.
+func absURL(baseURL string, p string) string {
+	if baseURL == "" || strings.Contains(p, "://") || strings.HasPrefix(p, "//") {
+		return p
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(p, "/")
+}
+
+func readingTime(s any) int {
+	return max(1, (len(words(s))+199)/200)
+}
+
+func toJSON(v any) (template.JS, error) {
+	b, err := json.Marshal(v)
+	return template.JS(b), err
+}
+
+// Returns the slice list as a reflect.Value, or an error if it isn't a slice.
+func sliceValue(list any) (reflect.Value, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("expected a slice, got %T", list)
+	}
+	return v, nil
+}
+
+func first(n int, list any) (any, error) {
+	v, err := sliceValue(list)
+	if err != nil {
+		return nil, err
+	}
+	return v.Slice(0, min(max(n, 0), v.Len())).Interface(), nil
+}
+
+func where(list any, key string, value any) (any, error) {
+	v, err := sliceValue(list)
+	if err != nil {
+		return nil, err
+	}
+	filtered := reflect.MakeSlice(v.Type(), 0, v.Len())
+	for i := range v.Len() {
+		field, err := lookup(v.Index(i), key)
+		if err != nil {
+			return nil, err
+		}
+		if matches(field, value) {
+			filtered = reflect.Append(filtered, v.Index(i))
+		}
+	}
+	return filtered.Interface(), nil
+}
+
+func sortBy(list any, key string, order ...string) (any, error) {
+	v, err := sliceValue(list)
+	if err != nil {
+		return nil, err
+	}
+	descending := false
+	if len(order) > 0 {
+		switch order[0] {
+		case "asc":
+		case "desc":
+			descending = true
+		default:
+			return nil, fmt.Errorf("unknown order %q, must be asc or desc", order[0])
+		}
+	}
+	type element struct {
+		value reflect.Value
+		key   reflect.Value
+	}
+	elements := make([]element, v.Len())
+	for i := range elements {
+		k, err := lookup(v.Index(i), key)
+		if err != nil {
+			return nil, err
+		}
+		elements[i] = element{v.Index(i), k}
+	}
+	var compareErr error
+	slices.SortStableFunc(elements, func(a, b element) int {
+		c, err := compareValues(a.key, b.key)
+		compareErr = cmp.Or(compareErr, err)
+		if descending {
+			return -c
+		}
+		return c
+	})
+	if compareErr != nil {
+		return nil, compareErr
+	}
+	sorted := reflect.MakeSlice(v.Type(), 0, len(elements))
+	for _, e := range elements {
+		sorted = reflect.Append(sorted, e.value)
+	}
+	return sorted.Interface(), nil
+}
+
+// Returns the field or method key of v, which may be a path of nested fields and methods, e.g. Published.Year.
+// Methods must not take arguments and may return an error as second result.
+func lookup(v reflect.Value, key string) (reflect.Value, error) {
+	for _, name := range strings.Split(key, ".") {
+		for v.Kind() == reflect.Interface && !v.IsNil() {
+			v = v.Elem()
+		}
+		method := v.MethodByName(name)
+		if !method.IsValid() && v.CanAddr() {
+			method = v.Addr().MethodByName(name)
+		}
+		if method.IsValid() && method.Type().NumIn() == 0 && method.Type().NumOut() > 0 {
+			results := method.Call(nil)
+			if len(results) == 2 && !results[1].IsNil() {
+				return reflect.Value{}, results[1].Interface().(error)
+			}
+			v = results[0]
+			continue
+		}
+		for v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		switch {
+		case v.Kind() == reflect.Struct && v.FieldByName(name).IsValid() && exported(name):
+			v = v.FieldByName(name)
+		case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return reflect.Value{}, fmt.Errorf("map has no key %v", name)
+			}
+		default:
+			return reflect.Value{}, fmt.Errorf("can't evaluate field %v in type %v", name, v.Type())
+		}
+	}
+	return v, nil
+}
+
+// Reports whether the field name is exported.
+func exported(name string) bool {
+	return name != "" && strings.ToUpper(name[:1]) == name[:1]
+}
+
+// Reports whether field equals value or, if field is a slice, contains it. Values of different types are compared
+// by their text, so that e.g. tags given as strings match terms.
+func matches(field reflect.Value, value any) bool {
+	field, isNil := indirect(field)
+	if isNil {
+		return value == nil
+	}
+	if field.Kind() == reflect.Slice || field.Kind() == reflect.Array {
+		for i := range field.Len() {
+			if matches(field.Index(i), value) {
+				return true
+			}
+		}
+		return false
+	}
+	if field.Type() == reflect.TypeOf(value) && field.Comparable() {
+		return field.Interface() == value
+	}
+	return text(field.Interface()) == text(value)
+}
+
+// Compares two values of the same kind: numbers, strings, booleans and times. Nil pointers come first.
+func compareValues(a reflect.Value, b reflect.Value) (int, error) {
+	a, aNil := indirect(a)
+	b, bNil := indirect(b)
+	if aNil || bNil {
+		return -cmp.Compare(boolToInt(aNil), boolToInt(bNil)), nil
+	}
+	if t, ok := a.Interface().(time.Time); ok {
+		if u, ok := b.Interface().(time.Time); ok {
+			return t.Compare(u), nil
+		}
+	}
+	switch {
+	case a.CanInt() && b.CanInt():
+		return cmp.Compare(a.Int(), b.Int()), nil
+	case a.CanUint() && b.CanUint():
+		return cmp.Compare(a.Uint(), b.Uint()), nil
+	case a.CanFloat() && b.CanFloat():
+		return cmp.Compare(a.Float(), b.Float()), nil
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return strings.Compare(a.String(), b.String()), nil
+	case a.Kind() == reflect.Bool && b.Kind() == reflect.Bool:
+		return cmp.Compare(boolToInt(a.Bool()), boolToInt(b.Bool())), nil
+	}
+	return 0, errors.New("can only sort by numbers, strings, booleans and times, got " + a.Type().String())
+}
+
+// Dereferences pointers and interfaces, reporting whether one of them is nil.
+func indirect(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, true
+		}
+		v = v.Elem()
+	}
+	return v, false
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
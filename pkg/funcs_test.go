@@ -0,0 +1,70 @@
+package microblog
+
+import (
+	"html/template"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	date := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	type item struct {
+		Name string
+		Tags []string
+		Date *time.Time
+	}
+	later := date.AddDate(1, 0, 0)
+	items := []item{{"b", []string{"go"}, &later}, {"a", []string{"go", "web"}, &date}, {"c", nil, nil}}
+	data := map[string]any{"Tags": []string{"go"}, "Date": date, "Items": items, "Dated": items[:2], "Text": "<p>one two &amp; three</p>"}
+
+	for text, expected := range map[string]string{
+		`{{.Date | date "2006-01-02"}}`:                            "2024-03-05",
+		`{{truncate 3 "hello"}} {{truncate 5 "hello"}}`:            "hel… hello",
+		`{{excerpt 2 .Text}}|{{excerpt 5 .Text}}`:                  "one two…|one two &amp; three",
+		`{{markdownify "*hey*"}}`:                                  "<em>hey</em>",
+		`{{slugify "Hello World"}}`:                                "hello-world",
+		`{{absURL "/posts/a/"}} {{absURL "https://b.org/"}}`:       "https://example.org/blog/posts/a/ https://b.org/",
+		`{{readingTime .Text}}`:                                    "1",
+		`{{range where .Items "Tags" "web"}}{{.Name}}{{end}}`:      "a",
+		`{{range where .Items "Name" "c"}}{{.Name}}{{end}}`:        "c",
+		`{{range sortBy .Items "Name"}}{{.Name}}{{end}}`:           "abc",
+		`{{range sortBy .Items "Date" "desc"}}{{.Name}}{{end}}`:    "bac",
+		`{{range sortBy .Dated "Date.Year"}}{{.Name}}{{end}}`:      "ab",
+		`{{range first 2 (sortBy .Items "Name")}}{{.Name}}{{end}}`: "ab",
+		`<script>var items = {{json .Items}}</script>`:             `<script>var items = [{"Name":"b","Tags":["go"],"Date":"2025-03-05T00:00:00Z"},{"Name":"a","Tags":["go","web"],"Date":"2024-03-05T00:00:00Z"},{"Name":"c","Tags":null,"Date":null}]</script>`,
+		`<p title="{{json .Tags}}">`:                               `<p title="[&#34;go&#34;]">`,
+	} {
+		tmpl, err := template.New("").Funcs(TemplateFuncs("https://example.org/blog/")).Parse(text)
+		if err != nil {
+			t.Fatalf("could not parse %q: %v", text, err)
+		}
+		var s strings.Builder
+		if err := tmpl.Execute(&s, data); err != nil {
+			t.Errorf("could not execute %q: %v", text, err)
+			continue
+		}
+		if s.String() != expected {
+			t.Errorf("expected %q for %q, got %q", expected, text, s.String())
+		}
+	}
+
+	// the items are sorted by a copy
+	if names := []string{items[0].Name, items[1].Name, items[2].Name}; !slices.Equal(names, []string{"b", "a", "c"}) {
+		t.Errorf("expected the slice to be unchanged, got %v", names)
+	}
+
+	for text, expected := range map[string]string{
+		`{{date "2006" "yesterday"}}`:         "date expects a time.Time, got string",
+		`{{where .Items "Nope" "a"}}`:         "can't evaluate field Nope in type microblog.item",
+		`{{sortBy .Items "Name" "sideways"}}`: `unknown order "sideways", must be asc or desc`,
+		`{{sortBy .Items "Tags"}}`:            "can only sort by numbers, strings, booleans and times",
+		`{{first 1 "abc"}}`:                   "expected a slice, got string",
+	} {
+		tmpl := template.Must(template.New("").Funcs(TemplateFuncs("")).Parse(text))
+		if err := tmpl.Execute(&strings.Builder{}, data); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing %q for %q, got %v", expected, text, err)
+		}
+	}
+}
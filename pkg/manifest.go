@@ -0,0 +1,141 @@
+package microblog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File names of the manifests that NewBlog looks for in the blog directory. A manifest lists the posts of the
+// blog in display order, either as the `order` list of blog.yaml:
+//
+//	order:
+//	  - hello-world.md
+//	  - second-post.md
+//
+// or as one file name per line in order.txt (empty lines and lines starting with # are ignored).
+const (
+	ManifestYaml = "blog.yaml"
+	ManifestText = "order.txt"
+)
+
+// Returned by NewBlog if the manifest does not list exactly the posts found in the blog directory.
+type ManifestError struct {
+	File       string   // path to the manifest file, empty if the manifest was passed with WithManifest
+	Missing    []string // posts in the blog directory that are not listed in the manifest
+	Unknown    []string // entries in the manifest without a corresponding post
+	Duplicates []string // entries listed more than once
+}
+
+func (e *ManifestError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, fmt.Sprintf("missing posts %v", strings.Join(e.Missing, ", ")))
+	}
+	if len(e.Unknown) > 0 {
+		problems = append(problems, fmt.Sprintf("unknown posts %v", strings.Join(e.Unknown, ", ")))
+	}
+	if len(e.Duplicates) > 0 {
+		problems = append(problems, fmt.Sprintf("duplicate entries %v", strings.Join(e.Duplicates, ", ")))
+	}
+	manifest := "manifest"
+	if e.File != "" {
+		manifest = e.File
+	}
+	return fmt.Sprintf("%v does not match the blog posts: %v", manifest, strings.Join(problems, "; "))
+}
+
+// Compares the manifest entries with the names of the posts found in the blog directory.
+// Returns nil if every post is listed exactly once.
+func validateManifest(manifest []string, posts []*blogPost) *ManifestError {
+	e := &ManifestError{}
+	listed := make(map[string]bool, len(manifest))
+	for _, name := range manifest {
+		if listed[name] {
+			e.Duplicates = append(e.Duplicates, name)
+		}
+		listed[name] = true
+	}
+	found := make(map[string]bool, len(posts))
+	for _, p := range posts {
+		found[p.GetName()] = true
+		if !listed[p.GetName()] {
+			e.Missing = append(e.Missing, p.GetName())
+		}
+	}
+	for _, name := range manifest {
+		if !found[name] {
+			found[name] = true // report every unknown entry only once
+			e.Unknown = append(e.Unknown, name)
+		}
+	}
+	if len(e.Missing) == 0 && len(e.Unknown) == 0 && len(e.Duplicates) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Looks for a manifest file in the root of fsys and returns its path and entries. fsys is rooted at directory,
+// the returned path is the path of the manifest joined to directory with join. blog.yaml is only a manifest if it
+// has an `order` key, so it can hold other settings as well.
+// Returns an empty path if the directory doesn't contain a manifest.
+func readManifest(fsys fs.FS, directory string, join func(elem ...string) string) (string, []string, error) {
+	var found []string
+	var entries [][]string
+	for _, name := range []string{ManifestYaml, ManifestText} {
+		fp := join(directory, name)
+		content, err := fs.ReadFile(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return "", nil, fmt.Errorf("could not read file %v: %v", fp, err)
+		}
+		e, ok, err := parseManifest(name, content)
+		if err != nil {
+			return "", nil, fmt.Errorf("could not parse %v: %v", fp, err)
+		}
+		if ok {
+			found = append(found, fp)
+			entries = append(entries, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", nil, nil
+	case 1:
+		return found[0], entries[0], nil
+	default:
+		return "", nil, fmt.Errorf("found more than one manifest in %v: %v", directory, strings.Join(found, ", "))
+	}
+}
+
+// Returns the entries of the manifest file name with the given content, and whether the file is a manifest at all.
+func parseManifest(name string, content []byte) ([]string, bool, error) {
+	if name == ManifestYaml {
+		var m struct {
+			Order *[]string `yaml:"order"`
+		}
+		if err := yaml.Unmarshal(content, &m); err != nil {
+			return nil, false, err
+		}
+		if m.Order == nil {
+			return nil, false, nil
+		}
+		return *m.Order, true, nil
+	}
+	var entries []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, true, scanner.Err()
+}
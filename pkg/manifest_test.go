@@ -0,0 +1,100 @@
+package microblog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBlogManifestFile(t *testing.T) {
+	for _, tc := range []struct {
+		file    string
+		content string
+	}{
+		{ManifestYaml, "order:\n  - b.md\n  - c.md\n  - a.md\n"},
+		{ManifestText, "# display order\nb.md\n\nc.md\na.md\n"},
+	} {
+		d := t.TempDir()
+		os.WriteFile(filepath.Join(d, "a.md"), []byte("## Title\nhey"), 0644)
+		os.WriteFile(filepath.Join(d, "b.md"), []byte("## Title\nhey"), 0644)
+		os.WriteFile(filepath.Join(d, "c.md"), []byte("## Title\nhey"), 0644)
+		os.WriteFile(filepath.Join(d, tc.file), []byte(tc.content), 0644)
+
+		// the manifest takes precedence over the sort order
+		blog, err := NewBlog(d, WithSortOrder(SortByDateDescending))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		expected := []string{"b.md", "c.md", "a.md"}
+		if names := postNames(blog); !slices.Equal(names, expected) {
+			t.Errorf("%v: expected order %v, got %v", tc.file, expected, names)
+		}
+	}
+}
+
+func TestBlogManifestFileMismatch(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "c.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, ManifestText), []byte("a.md\nx.md\na.md\ny.md\n"), 0644)
+
+	_, err := NewBlog(d)
+	var manifestErr *ManifestError
+	if !errors.As(err, &manifestErr) {
+		t.Errorf("expected *ManifestError, got %v", err)
+		t.FailNow()
+	}
+	if manifestErr.File != filepath.Join(d, ManifestText) {
+		t.Errorf("expected error to reference %v, got %v", filepath.Join(d, ManifestText), manifestErr.File)
+	}
+	if !slices.Equal(manifestErr.Missing, []string{"b.md", "c.md"}) {
+		t.Errorf("expected missing posts b.md and c.md, got %v", manifestErr.Missing)
+	}
+	if !slices.Equal(manifestErr.Unknown, []string{"x.md", "y.md"}) {
+		t.Errorf("expected unknown posts x.md and y.md, got %v", manifestErr.Unknown)
+	}
+	if !slices.Equal(manifestErr.Duplicates, []string{"a.md"}) {
+		t.Errorf("expected duplicate entry a.md, got %v", manifestErr.Duplicates)
+	}
+}
+
+func TestBlogManifestYamlWithoutOrder(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, ManifestYaml), []byte("title: My blog\n"), 0644)
+
+	// blog.yaml without order doesn't define the order of the posts
+	blog, err := NewBlog(d, WithSortOrder(SortByName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := postNames(blog); !slices.Equal(names, []string{"a.md", "b.md"}) {
+		t.Errorf("expected posts sorted by name, got %v", names)
+	}
+
+	// nor does it conflict with order.txt
+	os.WriteFile(filepath.Join(d, ManifestText), []byte("b.md\na.md\n"), 0644)
+	blog, err = NewBlog(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := postNames(blog); !slices.Equal(names, []string{"b.md", "a.md"}) {
+		t.Errorf("expected posts in the order of %v, got %v", ManifestText, names)
+	}
+}
+
+func TestBlogMultipleManifestFiles(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, ManifestText), []byte("a.md\n"), 0644)
+	os.WriteFile(filepath.Join(d, ManifestYaml), []byte("order:\n  - a.md\n"), 0644)
+
+	if _, err := NewBlog(d); err == nil {
+		t.Error("expected error when there is more than one manifest")
+	}
+}
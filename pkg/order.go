@@ -0,0 +1,138 @@
+package microblog
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Order in which the posts of a Blog are returned and rendered.
+type SortOrder string
+
+const (
+	// Lexical order of the file names.
+	SortByName SortOrder = "name"
+	// Natural order of the file names, i.e. numbers within names are compared numerically ("2.md" < "10.md").
+	SortByNaturalName SortOrder = "natural"
+	// Publication date, oldest first.
+	SortByDateAscending SortOrder = "date-asc"
+	// Publication date, newest first.
+	SortByDateDescending SortOrder = "date-desc"
+	// Weight defined in the front matter of the posts, lowest first.
+	SortByWeight SortOrder = "weight"
+	// Explicit order defined with WithManifest.
+	SortByManifest SortOrder = "manifest"
+)
+
+// Returns the SortOrder represented by s, or an error if s is not a known sort order.
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch o := SortOrder(s); o {
+	case SortByName, SortByNaturalName, SortByDateAscending, SortByDateDescending, SortByWeight, SortByManifest:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown sort order %q", s)
+}
+
+// Compares two strings in natural order: runs of digits are compared by their numeric value,
+// everything else byte by byte.
+func naturalCompare(a string, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			numA, restA := splitDigits(a)
+			numB, restB := splitDigits(b)
+			trimmedA, trimmedB := strings.TrimLeft(numA, "0"), strings.TrimLeft(numB, "0")
+			if len(trimmedA) != len(trimmedB) {
+				return len(trimmedA) - len(trimmedB)
+			}
+			if c := strings.Compare(trimmedA, trimmedB); c != 0 {
+				return c
+			}
+			a, b = restA, restB
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func splitDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// Sorts posts in place according to the given order. Ties are always broken by the natural order of the
+// post names, so the result is deterministic. The manifest must have been validated against the posts
+// beforehand (see validateManifest).
+func sortPosts(posts []*blogPost, order SortOrder, manifest []string) error {
+	byName := func(a, b *blogPost) int {
+		if c := naturalCompare(a.GetName(), b.GetName()); c != 0 {
+			return c
+		}
+		return strings.Compare(a.GetName(), b.GetName())
+	}
+	switch order {
+	case "", SortByName:
+		slices.SortFunc(posts, func(a, b *blogPost) int {
+			return strings.Compare(a.GetName(), b.GetName())
+		})
+	case SortByNaturalName:
+		slices.SortFunc(posts, byName)
+	case SortByWeight:
+		slices.SortFunc(posts, func(a, b *blogPost) int {
+			if a.Weight != b.Weight {
+				return a.Weight - b.Weight
+			}
+			return byName(a, b)
+		})
+	case SortByDateAscending, SortByDateDescending:
+		// posts that haven't been published yet will be published today
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		dates := make(map[*blogPost]time.Time, len(posts))
+		for _, p := range posts {
+			dt, err := p.GetPublicationDate()
+			if err != nil {
+				return fmt.Errorf("could not determine publication date of %v: %v", p.GetFilePath(), err)
+			}
+			if dt == nil {
+				dt = &today
+			}
+			dates[p] = *dt
+		}
+		direction := 1
+		if order == SortByDateDescending {
+			direction = -1
+		}
+		slices.SortFunc(posts, func(a, b *blogPost) int {
+			if c := dates[a].Compare(dates[b]); c != 0 {
+				return direction * c
+			}
+			return byName(a, b) // ascending in both directions
+		})
+	case SortByManifest:
+		if manifest == nil {
+			return errors.New("no manifest found")
+		}
+		positions := make(map[string]int, len(manifest))
+		for i, name := range manifest {
+			positions[name] = i
+		}
+		slices.SortFunc(posts, func(a, b *blogPost) int {
+			return positions[a.GetName()] - positions[b.GetName()]
+		})
+	default:
+		return fmt.Errorf("unknown sort order %q", order)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package microblog
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	names := []string{"post10.md", "post2.md", "a.md", "post1.md", "post02b.md", "b.md"}
+	slices.SortFunc(names, naturalCompare)
+	expected := []string{"a.md", "b.md", "post1.md", "post2.md", "post02b.md", "post10.md"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+func postNames(b Blog) []string {
+	var names []string
+	for _, p := range b.GetBlogPosts() {
+		names = append(names, p.GetName())
+	}
+	return names
+}
+
+func TestBlogSortOrder(t *testing.T) {
+	d := t.TempDir()
+
+	os.WriteFile(filepath.Join(d, "2_second.md"), []byte("---\ndate: 2024-03-01\nweight: 1\n---\n## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "10_third.md"), []byte("---\ndate: 2024-01-01\nweight: 2\n---\n## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "1_first.md"), []byte("---\ndate: 2024-02-01\nweight: 3\n---\n## Title\nhey"), 0644)
+
+	for _, tc := range []struct {
+		options  []Option
+		expected []string
+	}{
+		{nil, []string{"10_third.md", "1_first.md", "2_second.md"}},
+		{[]Option{WithSortOrder(SortByName)}, []string{"10_third.md", "1_first.md", "2_second.md"}},
+		{[]Option{WithSortOrder(SortByNaturalName)}, []string{"1_first.md", "2_second.md", "10_third.md"}},
+		{[]Option{WithSortOrder(SortByDateAscending)}, []string{"10_third.md", "1_first.md", "2_second.md"}},
+		{[]Option{WithSortOrder(SortByDateDescending)}, []string{"2_second.md", "1_first.md", "10_third.md"}},
+		{[]Option{WithSortOrder(SortByWeight)}, []string{"2_second.md", "10_third.md", "1_first.md"}},
+		{[]Option{WithManifest("1_first.md", "10_third.md", "2_second.md")}, []string{"1_first.md", "10_third.md", "2_second.md"}},
+	} {
+		blog, err := NewBlog(d, tc.options...)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if names := postNames(blog); !slices.Equal(names, tc.expected) {
+			t.Errorf("expected order %v, got %v", tc.expected, names)
+		}
+	}
+}
+
+func TestBlogSortOrderEqualDates(t *testing.T) {
+	d := t.TempDir()
+
+	os.WriteFile(filepath.Join(d, "10_c.md"), []byte("---\ndate: 2024-01-01\n---\n## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "2_b.md"), []byte("---\ndate: 2024-01-01\n---\n## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "1_a.md"), []byte("---\ndate: 2024-01-01\n---\n## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "3_new.md"), []byte("---\ndate: 2024-02-01\n---\n## Title\nhey"), 0644)
+
+	// posts with the same date are in natural order of their names, whatever the direction
+	for order, expected := range map[SortOrder][]string{
+		SortByDateAscending:  {"1_a.md", "2_b.md", "10_c.md", "3_new.md"},
+		SortByDateDescending: {"3_new.md", "1_a.md", "2_b.md", "10_c.md"},
+	} {
+		blog, err := NewBlog(d, WithSortOrder(order))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := postNames(blog); !slices.Equal(names, expected) {
+			t.Errorf("%v: expected order %v, got %v", order, expected, names)
+		}
+	}
+}
+
+func TestBlogSortOrderInvalid(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "test.md"), []byte("## Title\nhey"), 0644)
+
+	if _, err := NewBlog(d, WithSortOrder("random")); err == nil {
+		t.Error("expected error for unknown sort order")
+	}
+	if _, err := NewBlog(d, WithManifest("other.md")); err == nil {
+		t.Error("expected error for post missing from the manifest")
+	}
+}
+
+func TestBlogSortOrderPublicationTracking(t *testing.T) {
+	d := t.TempDir()
+
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "c.md"), []byte("## Title\nhey"), 0644)
+
+	// a.md has been published before, b.md and c.md haven't been published yet and are thus the newest
+	registry, err := (&sqlitePool).Acquire(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, err := registry.DB.Exec("INSERT INTO posts VALUES ('a.md', '2024-01-01');"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	blog, err := NewBlog(d, WithPublicationTracking(), WithSortOrder(SortByDateDescending))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := []string{"b.md", "c.md", "a.md"} // b.md and c.md have the same date and are sorted by name
+	if names := postNames(blog); !slices.Equal(names, expected) {
+		t.Errorf("expected order %v, got %v", expected, names)
+	}
+
+	// sorting must not record a publication date
+	var count int
+	if err := registry.DB.QueryRow("SELECT COUNT(*) FROM posts;").Scan(&count); err != nil {
+		t.Error(err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 row in the registry, got %v", count)
+	}
+}
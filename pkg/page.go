@@ -0,0 +1,320 @@
+package microblog
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A page template (*.html.tmpl) of the website.
+type pageTemplate struct {
+	*template.Template
+	format bool             // format the generated HTML with gohtml
+	site   *site            // website the pages belong to
+	funcs  template.FuncMap // functions of the page templates, see pageFuncs
+}
+
+// Name of the page template of index.html and all other generated pages, e.g. the permalink pages.
+const indexTemplateFile = "index.html.tmpl"
+
+// Patterns (see fs.Glob) of the layouts and partials in the source directory of BuildSite, which are parsed together
+// with every page template and, if passed to WithPartials, the post template. A page can use a layout by defining its blocks and invoking it, e.g.
+//
+//	{{define "content"}}{{.}}{{end}}{{template "base.html.tmpl" .}}
+var PartialPatterns = []string{"_layouts/*.tmpl", "_partials/*.tmpl"}
+
+// Reports whether name is a directory of layouts or partials, see PartialPatterns.
+func isPartialDirectory(name string) bool {
+	return name == "_layouts" || name == "_partials"
+}
+
+// Returns the page templates (*.html.tmpl) in source: main is the template of index.html and all generated pages,
+// others are the templates of further pages in source or its subdirectories, e.g. about/index.html.tmpl. main is
+// index.html.tmpl, or the only page template at the top level of source.
+func findPageTemplates(source fs.FS) (string, []string, error) {
+	var names []string
+	err := fs.WalkDir(source, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("could not read the directory %v: %v", name, err)
+		}
+		if d.IsDir() && isPartialDirectory(name) {
+			return fs.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(name, ".html.tmpl") {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("error when trying to match template files: %v", err)
+	}
+	main := indexTemplateFile
+	if !slices.Contains(names, main) {
+		topLevel := slices.DeleteFunc(slices.Clone(names), func(name string) bool {
+			return strings.Contains(name, "/")
+		})
+		if len(topLevel) != 1 {
+			return "", nil, fmt.Errorf("expected %v or exactly 1 template file (*.html.tmpl) in the source directory, got %v", indexTemplateFile, len(topLevel))
+		}
+		main = topLevel[0]
+	}
+	return main, slices.DeleteFunc(names, func(name string) bool { return name == main }), nil
+}
+
+// Returns the functions of the page templates: TemplateFuncs with absURL resolving against options.BaseURL and
+// options.Funcs, which replace functions of the same name.
+func pageFuncs(options SiteOptions) template.FuncMap {
+	funcs := TemplateFuncs(options.BaseURL)
+	maps.Copy(funcs, options.Funcs)
+	return funcs
+}
+
+// Parses the page template name in source together with the layouts and partials in source, which are parsed
+// first so that the page can redefine their blocks. The template is named templateName in error messages, the
+// layouts and partials are named by their base name. All of them can use the functions funcs, see pageFuncs. Like in the post template, values are escaped according to their context,
+// only the content of a page ({{.}} and {{.Content}}) and the posts ({{.HTML}} of a post) are inserted as HTML.
+func parsePageTemplate(source fs.FS, name string, templateName string, funcs template.FuncMap) (*template.Template, error) {
+	tmplBytes, err := fs.ReadFile(source, name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file %v: %v", name, err)
+	}
+	t := template.New(templateName).Funcs(funcs)
+	for _, pattern := range PartialPatterns {
+		matches, err := fs.Glob(source, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error when trying to match template files: %v", err)
+		}
+		for _, match := range matches {
+			partial, err := fs.ReadFile(source, match)
+			if err != nil {
+				return nil, fmt.Errorf("could not read file %v: %v", match, err)
+			}
+			if _, err := t.New(path.Base(match)).Parse(string(partial)); err != nil {
+				return nil, fmt.Errorf("could not open template %v: %v", match, err)
+			}
+		}
+	}
+	t, err = t.Parse(string(tmplBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not open template: %v", err)
+	}
+	if t.Tree == nil {
+		return nil, fmt.Errorf("template tree of %v is empty", name)
+	}
+	return t, nil
+}
+
+// Data passed to the page template (*.html.tmpl). {{.Content}} inserts the rendered posts, or the content of pages
+// that don't list posts, e.g. the tag overview. Printing the page itself ({{.}}) inserts the content as well, see
+// page.Format. Within {{with}} or {{range}}, the content of the page is {{$.Content}}.
+type page struct {
+	Content    template.HTML // HTML of the page, inserted as is
+	Pagination pagination    // position of the page within a paginated listing
+	Site       *site         // website the page belongs to
+	BuildTime  time.Time     // time the website has been built
+	posts      []BlogPost
+	html       [][]byte // rendered posts, html[i] belongs to posts[i], or nil if they haven't been rendered
+}
+
+// Returns the content of the page as HTML, e.g. for {{.String}}.
+func (p page) String() template.HTML {
+	return p.Content
+}
+
+// Placeholder printed for a page, see page.Format. It consists of letters and digits only, so html/template prints
+// it as is in every context.
+const contentPlaceholder = "microblogGenPageContent7f3c9a"
+
+// Prints a placeholder for the content of the page, which pageTemplate.execute replaces with the content. html/template
+// escapes every printed value that isn't of a type like template.HTML, so this keeps {{.}} of the page inserting
+// the posts as HTML, like in page templates written before page data was added.
+func (p page) Format(f fmt.State, verb rune) {
+	io.WriteString(f, contentPlaceholder)
+}
+
+// Returns the posts listed on the page in the order they are listed, e.g. for a table of contents:
+//
+//	{{range .Posts}}<a href="{{.GetURL}}">{{.GetTitle}}</a> {{.DtPosted}}{{end}}
+func (p page) Posts() ([]post, error) {
+	posts := make([]post, 0, len(p.posts))
+	for i, bp := range p.posts {
+		rendered, err := bp.Render()
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post{RenderedPost: rendered})
+		if p.html != nil {
+			posts[i].html = p.html[i]
+		}
+	}
+	return posts, nil
+}
+
+// Returns the most recently published post of the website, see site.Latest.
+func (p page) Latest() (*post, error) {
+	return p.Site.Latest()
+}
+
+// A post listed on a page, see page.Posts. Besides the HTML of the post, the fields and methods of
+// RenderedPost are available, e.g. {{.GetTitle}}, {{.DtPosted}} and {{.Tags}}.
+type post struct {
+	RenderedPost
+	html []byte
+}
+
+// Returns the post rendered with the post template, as it appears in {{.Content}} of the page.
+func (p post) HTML() (template.HTML, error) {
+	if p.html != nil {
+		return template.HTML(p.html), nil
+	}
+	var buf bytes.Buffer
+	if err := p.WriteHtml(&buf); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// Data about the website, available as {{.Site}} in the page templates.
+type site struct {
+	Title      string    // title of the website, see SiteOptions
+	BaseURL    string    // URL the website is served from, see SiteOptions
+	BuildTime  time.Time // time the website has been built
+	PostCount  int       // number of posts, without archived posts
+	Tags       []Term    // all tags with the number of posts per tag
+	Categories []Term    // all categories with the number of posts per category
+	Sections   []string  // sections of the blog, see Blog.Sections
+	Series     []*Series // series of the blog
+	blog       Blog
+	latest     struct {
+		once sync.Once
+		post *post
+		err  error
+	}
+}
+
+// Returns the site data of blog, built at buildTime.
+func newSite(b Blog, options SiteOptions, buildTime time.Time) *site {
+	return &site{
+		Title:      options.Title,
+		BaseURL:    options.BaseURL,
+		BuildTime:  buildTime,
+		PostCount:  len(b.GetBlogPosts()),
+		Tags:       b.Tags(),
+		Categories: b.Categories(),
+		Sections:   b.Sections(),
+		Series:     b.Series(),
+		blog:       b,
+	}
+}
+
+// Returns the most recently published post of the website, or nil if there are no posts. Posts that haven't been
+// published before are published by the current build.
+func (s *site) Latest() (*post, error) {
+	s.latest.once.Do(func() {
+		if s.blog == nil {
+			return
+		}
+		var latest BlogPost
+		var latestDate time.Time
+		for _, p := range s.blog.GetBlogPosts() {
+			date, err := p.GetPublicationDate()
+			if err != nil {
+				s.latest.err = err
+				return
+			}
+			if date == nil {
+				date = &s.BuildTime
+			}
+			if latest == nil || date.After(latestDate) {
+				latest, latestDate = p, *date
+			}
+		}
+		if latest == nil {
+			return
+		}
+		rendered, err := latest.Render()
+		if err != nil {
+			s.latest.err = err
+			return
+		}
+		s.latest.post = &post{RenderedPost: rendered}
+	})
+	return s.latest.post, s.latest.err
+}
+
+// Executes the template with data and writes the output to w. If data is a page, its content is inserted where the
+// page itself is printed, see page.Format.
+func (t *pageTemplate) execute(w io.Writer, data any) error {
+	p, ok := data.(page)
+	if !ok {
+		return t.Execute(w, data)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, p); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.ReplaceAll(buf.Bytes(), []byte(contentPlaceholder), []byte(p.Content)))
+	return err
+}
+
+// Returns a page with the given content that doesn't list posts and isn't paginated.
+func (t *pageTemplate) singlePage(content template.HTML) page {
+	return page{Content: content, Pagination: newPagination("/", 1, 1), Site: t.site, BuildTime: t.site.BuildTime}
+}
+
+// Returns a page listing posts, html[i] is the HTML of posts[i] or nil to render the posts when they are accessed.
+func (t *pageTemplate) listing(posts []BlogPost, html [][]byte, pagination pagination) page {
+	var content []byte
+	if html != nil {
+		content = bytes.Join(html, nil)
+	}
+	p := t.singlePage(template.HTML(content))
+	p.Pagination, p.posts, p.html = pagination, posts, html
+	return p
+}
+
+// Position of a page within a paginated listing. Pages that aren't paginated have a single page.
+type pagination struct {
+	Current int    // number of the current page, starting at 1
+	Total   int    // total number of pages
+	PrevURL string // URL of the previous page, empty on the first page
+	NextURL string // URL of the next page, empty on the last page
+	baseURL string
+}
+
+// Creates the pagination data for page current of total pages of the listing at baseURL (e.g. /).
+func newPagination(baseURL string, current int, total int) pagination {
+	p := pagination{Current: current, Total: total, baseURL: baseURL}
+	if current > 1 {
+		p.PrevURL = p.URL(current - 1)
+	}
+	if current < total {
+		p.NextURL = p.URL(current + 1)
+	}
+	return p
+}
+
+// Returns the URL of page n: the base URL for the first page and <base>page/<n>/ for all others.
+func (p pagination) URL(n int) string {
+	if n <= 1 {
+		return p.baseURL
+	}
+	return fmt.Sprintf("%vpage/%v/", p.baseURL, n)
+}
+
+// Returns the page numbers 1 to Total, e.g. to render links to all pages with {{range .Pagination.Pages}}.
+func (p pagination) Pages() []int {
+	pages := make([]int, p.Total)
+	for i := range pages {
+		pages[i] = i + 1
+	}
+	return pages
+}
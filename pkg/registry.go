@@ -27,7 +27,8 @@ const (
 
 type sqliteRegistry struct {
 	DB       *sql.DB
-	location string // path to db file
+	location string     // path to db file
+	mu       sync.Mutex // guards GetOrSetPublicationDate
 }
 
 func (r *sqliteRegistry) GetLocation() string {
@@ -36,6 +37,7 @@ func (r *sqliteRegistry) GetLocation() string {
 
 type sqlitePoolType struct {
 	sync.Map
+	mu sync.Mutex // guards the creation of registries
 }
 
 var sqlitePool sqlitePoolType // singleton
@@ -48,18 +50,33 @@ func (pool *sqlitePoolType) Acquire(directory string) (*sqliteRegistry, error) {
 		return loadedRegistry.(*sqliteRegistry), nil
 	}
 
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if loadedRegistry, ok := pool.Load(directory); ok { // created concurrently
+		return loadedRegistry.(*sqliteRegistry), nil
+	}
 	registry, err := createSqliteRegistry(directory)
 	if err != nil {
 		return nil, err
 	}
-	pool.LoadOrStore(directory, registry)
+	pool.Store(directory, registry)
 	return registry, nil
 }
 
+// Returns the registry of the configured backend (microblog.DefaultOptions.Backend) for the given directory.
+func acquireRegistry(directory string) (*sqliteRegistry, error) {
+	switch DefaultOptions.Backend {
+	case SQLite:
+		return (&sqlitePool).Acquire(directory)
+	default:
+		return nil, fmt.Errorf("unrecognised backend option %v", DefaultOptions.Backend)
+	}
+}
+
 func createSqliteRegistry(directory string) (*sqliteRegistry, error) {
 	fp := filepath.Join(directory, "blog.sqlite")
 
-	f, err := os.Create(fp)
+	f, err := os.OpenFile(fp, os.O_RDWR|os.O_CREATE, 0644) // don't truncate existing registries
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +86,8 @@ func createSqliteRegistry(directory string) (*sqliteRegistry, error) {
 	if err != nil {
 		return nil, err
 	}
+	// sqlite allows only one writer at a time, serialise the queries of posts rendered concurrently
+	db.SetMaxOpenConns(1)
 
 	// initialise table if necessary
 	_, err = db.Exec(`
@@ -77,6 +96,11 @@ func createSqliteRegistry(directory string) (*sqliteRegistry, error) {
 			dt_posted DATE DEFAULT CURRENT_DATE
 		);
 		CREATE UNIQUE INDEX IF NOT EXISTS name_idx ON posts (name);
+		CREATE TABLE IF NOT EXISTS render_cache (
+			name TEXT NOT NULL PRIMARY KEY,
+			key TEXT NOT NULL,
+			html BLOB NOT NULL
+		);
 	`)
 	if err != nil {
 		return nil, err
@@ -113,6 +137,19 @@ func (r *sqliteRegistry) SetPublicationDate(p BlogPost, t *time.Time) (*time.Tim
 	return dtPosted, nil
 }
 
+// Get the publication date for a given BlogPost, setting it to t (see SetPublicationDate) if there is no entry for
+// the blog post yet. Unlike calling GetPublicationDate and SetPublicationDate, this is safe when the same blog post
+// is rendered concurrently.
+func (r *sqliteRegistry) GetOrSetPublicationDate(p BlogPost, t *time.Time) (*time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dtPosted, err := r.GetPublicationDate(p)
+	if err != nil || dtPosted != nil {
+		return dtPosted, err
+	}
+	return r.SetPublicationDate(p, t)
+}
+
 // Get the publication date for a given BlogPost.
 // If there is no entry for that blog post in the database (and there is no error otherwise),
 // the function will return (nil, nil).
@@ -129,3 +166,59 @@ func (r *sqliteRegistry) GetPublicationDate(p BlogPost) (*time.Time, error) {
 
 	return dtPosted, nil
 }
+
+// Get the HTML cached for a given blog post under key (see WithRenderCache).
+// Returns nil if nothing is cached for the blog post or the cached HTML has a different key.
+func (r *sqliteRegistry) GetCachedHtml(p BlogPost, key string) ([]byte, error) {
+	row := r.DB.QueryRow("SELECT html FROM render_cache WHERE name = ? AND key = ?;", p.GetName(), key)
+	var html []byte
+	if err := row.Scan(&html); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return html, nil
+}
+
+// Cache the HTML of a given blog post under key, replacing the HTML cached for the blog post before.
+func (r *sqliteRegistry) SetCachedHtml(p BlogPost, key string, html []byte) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO render_cache VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET key = excluded.key, html = excluded.html;
+	`, p.GetName(), key, html)
+	return err
+}
+
+// Renames registry entries from Rename.From to Rename.To and removes the cached HTML of the renamed posts. The rows
+// are updated in a transaction that is only committed if apply (e.g. the renaming of the corresponding files)
+// succeeds as well.
+func (r *sqliteRegistry) RenamePosts(renames []Rename, apply func() error) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// move rows to temporary names first, the new names might still be in use by other posts
+	for i, rename := range renames {
+		if _, err := tx.Exec("UPDATE posts SET name = ? WHERE name = ?;", fmt.Sprintf("\x00%v", i), rename.From); err != nil {
+			return fmt.Errorf("could not rename registry entry %v: %v", rename.From, err)
+		}
+	}
+	for i, rename := range renames {
+		if _, err := tx.Exec("UPDATE posts SET name = ? WHERE name = ?;", rename.To, fmt.Sprintf("\x00%v", i)); err != nil {
+			return fmt.Errorf("could not rename registry entry %v to %v: %v", rename.From, rename.To, err)
+		}
+	}
+	// the key of the render cache includes the name of the post, so the cached HTML of renamed posts can't be reused
+	for _, rename := range renames {
+		if _, err := tx.Exec("DELETE FROM render_cache WHERE name IN (?, ?);", rename.From, rename.To); err != nil {
+			return fmt.Errorf("could not remove cached html of %v: %v", rename.From, err)
+		}
+	}
+	if err := apply(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -171,3 +171,43 @@ func TestSqliteRegistry(t *testing.T) {
 		}
 	})
 }
+
+func TestSqliteRegistryReopen(t *testing.T) {
+	d := t.TempDir()
+
+	fp := filepath.Join(d, "test.md")
+	if err := os.WriteFile(fp, []byte("## hey"), 0644); err != nil {
+		t.Error(err)
+	}
+	post, err := NewBlogPost(fp)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	registry, err := createSqliteRegistry(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	newDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := registry.SetPublicationDate(post, &newDate); err != nil {
+		t.Error(err)
+	}
+	registry.DB.Close()
+
+	// opening the registry again (e.g. in the next build) must not discard existing entries
+	registry, err = createSqliteRegistry(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer registry.DB.Close()
+	returnedDate, err := registry.GetPublicationDate(post)
+	if err != nil {
+		t.Error(err)
+	}
+	if returnedDate == nil || *returnedDate != newDate {
+		t.Errorf("expected publication date %v to survive reopening the registry, got %v", newDate, returnedDate)
+	}
+}
@@ -0,0 +1,188 @@
+package microblog
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Compute the n most related posts of every post (see BlogPost.Related). Relatedness combines the overlap of
+// the tags of two posts with the similarity of their text (TF-IDF weighted cosine similarity).
+func WithRelatedPosts(n int) BlogOption {
+	return func(b *blog) error {
+		if n < 0 {
+			return fmt.Errorf("number of related posts must not be negative, got %v", n)
+		}
+		b.related = n
+		return nil
+	}
+}
+
+// Splits text into lower case words of at least three letters or digits.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return slices.DeleteFunc(words, func(w string) bool {
+		return len(w) < 3
+	})
+}
+
+type weightedTerm struct {
+	term   int
+	weight float64
+}
+
+// Computes the L2-normalised TF-IDF vectors of the given documents, using a smoothed IDF so that terms shared by
+// all documents of a small collection still count. In larger collections, terms that occur in more than half of
+// the documents carry little information and are dropped, which also keeps the inverted index small.
+func tfidf(documents [][]string) [][]weightedTerm {
+	ids := make(map[string]int)
+	counts := make([]map[int]int, len(documents))
+	var df []int
+	for i, words := range documents {
+		counts[i] = make(map[int]int)
+		for _, w := range words {
+			id, ok := ids[w]
+			if !ok {
+				id = len(ids)
+				ids[w] = id
+				df = append(df, 0)
+			}
+			if counts[i][id] == 0 {
+				df[id]++
+			}
+			counts[i][id]++
+		}
+	}
+	n := float64(len(documents))
+	vectors := make([][]weightedTerm, len(documents))
+	for i, c := range counts {
+		for id, count := range c {
+			if len(documents) >= 10 && float64(df[id]) > n/2 {
+				continue
+			}
+			weight := float64(count) / float64(len(documents[i])) * math.Log(1+n/float64(df[id]))
+			vectors[i] = append(vectors[i], weightedTerm{id, weight})
+		}
+		// fixed order of terms, floating point sums must not depend on the iteration order of the map
+		slices.SortFunc(vectors[i], func(a, b weightedTerm) int {
+			return cmp.Compare(a.term, b.term)
+		})
+		var norm float64
+		for _, t := range vectors[i] {
+			norm += t.weight * t.weight
+		}
+		norm = math.Sqrt(norm)
+		for j := range vectors[i] {
+			vectors[i][j].weight /= norm
+		}
+	}
+	return vectors
+}
+
+// Sets the n most related posts of every post. The score of two posts is the sum of their tag overlap (Jaccard
+// index) and the cosine similarity of their text, both between 0 and 1. Posts without anything in common are
+// never related. Ties are broken by the order of posts, so the result is deterministic.
+func linkRelatedPosts(posts []*blogPost, n int) error {
+	documents := make([][]string, len(posts))
+	tags := make([][]string, len(posts))
+	for i, p := range posts {
+		file, err := p.Markdown()
+		if err != nil {
+			return err
+		}
+		_, body := splitFrontMatter([]byte(file))
+		documents[i] = tokenize(string(body))
+		for _, t := range p.GetTags() {
+			tags[i] = append(tags[i], t.Slug)
+		}
+	}
+	vectors := tfidf(documents)
+
+	// inverted indexes: term -> posts containing the term, tag -> posts with the tag
+	type posting struct {
+		index  int
+		weight float64
+	}
+	postings := make(map[int][]posting)
+	for i, v := range vectors {
+		for _, t := range v {
+			postings[t.term] = append(postings[t.term], posting{i, t.weight})
+		}
+	}
+	tagged := make(map[string][]int)
+	for i, t := range tags {
+		for _, tag := range t {
+			tagged[tag] = append(tagged[tag], i)
+		}
+	}
+
+	type candidate struct {
+		index int
+		score float64
+	}
+	scores := make([]float64, len(posts))
+	shared := make([]int, len(posts))
+	var touched []int
+	for i, p := range posts {
+		touched = touched[:0]
+		for _, t := range vectors[i] {
+			for _, other := range postings[t.term] {
+				if other.index == i {
+					continue
+				}
+				if scores[other.index] == 0 {
+					touched = append(touched, other.index)
+				}
+				scores[other.index] += t.weight * other.weight
+			}
+		}
+		for _, tag := range tags[i] {
+			for _, j := range tagged[tag] {
+				if j != i {
+					shared[j]++
+				}
+			}
+		}
+		for _, tag := range tags[i] {
+			for _, j := range tagged[tag] {
+				if j != i && shared[j] > 0 { // Jaccard index of the tags
+					if scores[j] == 0 {
+						touched = append(touched, j)
+					}
+					scores[j] += float64(shared[j]) / float64(len(tags[i])+len(tags[j])-shared[j])
+					shared[j] = 0
+				}
+			}
+		}
+		// keep the n best candidates, best first
+		better := func(a, b candidate) bool {
+			return a.score > b.score || (a.score == b.score && a.index < b.index)
+		}
+		best := make([]candidate, 0, n+1)
+		for _, j := range touched {
+			c := candidate{j, scores[j]}
+			scores[j] = 0
+			if c.score <= 1e-9 || (len(best) == n && !better(c, best[n-1])) {
+				continue
+			}
+			k := len(best)
+			for k > 0 && better(c, best[k-1]) {
+				k--
+			}
+			best = slices.Insert(best, k, c)
+			if len(best) > n {
+				best = best[:n]
+			}
+		}
+		p.related = nil
+		for _, c := range best {
+			p.related = append(p.related, posts[c.index])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package microblog
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func relatedNames(p BlogPost) []string {
+	var names []string
+	for _, r := range p.Related() {
+		names = append(names, r.GetName())
+	}
+	return names
+}
+
+func TestBlogRelatedPosts(t *testing.T) {
+	d := t.TempDir()
+
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("---\ntags: [go]\n---\n## Goroutines\nChannels and goroutines make concurrency in golang pleasant."), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("## Channels\nBuffered channels decouple goroutines from each other."), 0644)
+	os.WriteFile(filepath.Join(d, "c.md"), []byte("---\ntags: [go]\n---\n## Modules\nVersioning dependencies with modules."), 0644)
+	os.WriteFile(filepath.Join(d, "d.md"), []byte("## Sourdough\nFeeding the starter before baking bread."), 0644)
+
+	// not computed by default
+	blog, err := NewBlog(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(blog.GetBlogPosts()[0].Related()) != 0 {
+		t.Error("expected no related posts without WithRelatedPosts")
+	}
+
+	blog, err = NewBlog(d, WithRelatedPosts(2))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	posts := blog.GetBlogPosts()
+	// b.md shares words, c.md shares the tag
+	if names := relatedNames(posts[0]); len(names) != 2 || !slices.Contains(names, "b.md") || !slices.Contains(names, "c.md") {
+		t.Errorf("expected b.md and c.md to be related to a.md, got %v", names)
+	}
+	if names := relatedNames(posts[3]); len(names) != 0 {
+		t.Errorf("expected no posts related to d.md, got %v", names)
+	}
+
+	if _, err := NewBlog(d, WithRelatedPosts(-1)); err == nil {
+		t.Error("expected error for negative number of related posts")
+	}
+}
+
+func TestBlogRelatedPostsTemplate(t *testing.T) {
+	d := t.TempDir()
+
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("## First\nsourdough starter"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("## Second\nsourdough bread"), 0644)
+
+	blog, err := NewBlog(d, WithRelatedPosts(3), WithTemplateString(`{{range .Related}}{{.GetTitle}}{{end}};`))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	html, err := blog.RenderPosts()
+	if err != nil {
+		t.Error(err)
+	}
+	if string(html) != "Second;First;" {
+		t.Errorf("expected related posts in template, got %s", html)
+	}
+}
+
+func writeRandomPosts(tb testing.TB, d string, n int) {
+	words := strings.Fields("go rust python channels goroutines modules bread sourdough starter baking coffee tea " +
+		"espresso garden tomatoes compost bicycle commute weather rain travel train station library books novel")
+	tags := []string{"go", "food", "garden", "travel", "books"}
+	r := rand.New(rand.NewSource(1))
+	for i := range n {
+		var text strings.Builder
+		for range 200 {
+			text.WriteString(words[r.Intn(len(words))])
+			text.WriteString(fmt.Sprintf("%v ", r.Intn(50)))
+		}
+		content := fmt.Sprintf("---\ntags: [%v]\n---\n## Post %v\n%v", tags[r.Intn(len(tags))], i, text.String())
+		if err := os.WriteFile(filepath.Join(d, fmt.Sprintf("post%04d.md", i)), []byte(content), 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestBlogRelatedPostsDeterministic(t *testing.T) {
+	d := t.TempDir()
+	writeRandomPosts(t, d, 100)
+
+	var previous [][]string
+	for range 3 {
+		blog, err := NewBlog(d, WithRelatedPosts(5))
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		var related [][]string
+		for _, p := range blog.GetBlogPosts() {
+			related = append(related, relatedNames(p))
+		}
+		if previous != nil && !slices.EqualFunc(previous, related, slices.Equal) {
+			t.Error("expected related posts to be the same for every run")
+		}
+		previous = related
+	}
+}
+
+func BenchmarkRelatedPosts(b *testing.B) {
+	d := b.TempDir()
+	writeRandomPosts(b, d, 2000)
+
+	blog, err := NewBlog(d)
+	if err != nil {
+		b.Error(err)
+		b.FailNow()
+	}
+	var posts []*blogPost
+	for _, p := range blog.GetBlogPosts() {
+		posts = append(posts, p.(*blogPost))
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		if err := linkRelatedPosts(posts, 5); err != nil {
+			b.Error(err)
+			b.FailNow()
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package microblog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+var numericPrefix = regexp.MustCompile(`^\d+_`)
+
+// Renaming of a post file, from one file name to another.
+type Rename struct {
+	From string
+	To   string
+}
+
+// Changes the order of the post names passed to Reorder.
+type ReorderOperation func(names []string) ([]string, error)
+
+// Move the post with the given file name to position (starting at 1).
+func MovePost(name string, position int) ReorderOperation {
+	return func(names []string) ([]string, error) {
+		i := slices.Index(names, name)
+		if i == -1 {
+			return nil, fmt.Errorf("post %v does not exist", name)
+		}
+		return insertAt(slices.Delete(names, i, i+1), name, position)
+	}
+}
+
+// Insert a new post, i.e. a file without numeric prefix, at position (starting at 1).
+func InsertPost(name string, position int) ReorderOperation {
+	return func(names []string) ([]string, error) {
+		if hasNumericPrefix(name) {
+			return nil, fmt.Errorf("post %v already has a numeric prefix, use MovePost instead", name)
+		}
+		i := slices.Index(names, name)
+		if i == -1 {
+			return nil, fmt.Errorf("post %v does not exist", name)
+		}
+		return insertAt(slices.Delete(names, i, i+1), name, position)
+	}
+}
+
+func insertAt(names []string, name string, position int) ([]string, error) {
+	if position < 1 || position > len(names)+1 {
+		return nil, fmt.Errorf("position %v is out of range (1-%v)", position, len(names)+1)
+	}
+	return slices.Insert(names, position-1, name), nil
+}
+
+// Renames the Markdown files in directory so that their names carry a dense numeric prefix (001_, 002_, ...).
+// The current order is the natural order of the file names, files without prefix come last. Operations such as
+// MovePost and InsertPost are applied to that order before renumbering. The publication dates recorded in the
+// registry are migrated in the same transaction, so that renamed posts keep their dates.
+// If dryRun is set, the renames are computed but not applied.
+//
+//	renames, err := microblog.Reorder("/path/to/blog", false, microblog.MovePost("003_news.md", 1))
+func Reorder(directory string, dryRun bool, operations ...ReorderOperation) ([]Rename, error) {
+	if fp, _, err := readManifest(os.DirFS(directory), directory, filepath.Join); err != nil {
+		return nil, err
+	} else if fp != "" {
+		return nil, fmt.Errorf("the order of the posts is defined by %v, numeric prefixes are not needed", fp)
+	}
+	markdownFiles, err := filepath.Glob(filepath.Join(directory, "*.md"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for markdown files in %v: %v", directory, err)
+	}
+	names := make([]string, 0, len(markdownFiles))
+	for _, md := range markdownFiles {
+		names = append(names, filepath.Base(md))
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		if hasNumericPrefix(a) != hasNumericPrefix(b) {
+			if hasNumericPrefix(a) {
+				return -1
+			}
+			return 1
+		}
+		return naturalCompare(a, b)
+	})
+	for _, o := range operations {
+		if names, err = o(names); err != nil {
+			return nil, err
+		}
+	}
+
+	width := max(3, len(fmt.Sprint(len(names))))
+	var renames []Rename
+	for i, name := range names {
+		to := fmt.Sprintf("%0*d_%v", width, i+1, numericPrefix.ReplaceAllString(name, ""))
+		if to != name {
+			renames = append(renames, Rename{From: name, To: to})
+		}
+	}
+	if dryRun || len(renames) == 0 {
+		return renames, nil
+	}
+
+	renameFiles := func() error {
+		return renamePostFiles(directory, renames)
+	}
+	if _, err := os.Stat(filepath.Join(directory, "blog.sqlite")); errors.Is(err, os.ErrNotExist) {
+		return renames, renameFiles() // no publication dates to migrate
+	}
+	registry, err := acquireRegistry(directory)
+	if err != nil {
+		return nil, err
+	}
+	renamed := false
+	if err := registry.RenamePosts(renames, func() error {
+		err := renameFiles()
+		renamed = err == nil
+		return err
+	}); err != nil {
+		if renamed { // the transaction failed after the files have been renamed
+			reverse := make([]Rename, 0, len(renames))
+			for _, r := range renames {
+				reverse = append(reverse, Rename{From: r.To, To: r.From})
+			}
+			if revertErr := renamePostFiles(directory, reverse); revertErr != nil {
+				return nil, errors.Join(err, revertErr)
+			}
+		}
+		return nil, err
+	}
+	return renames, nil
+}
+
+// Renames the files in directory. Files are moved to temporary names first, since new names might still be
+// in use. If a rename fails, all previous renames are reverted.
+func renamePostFiles(directory string, renames []Rename) error {
+	type move struct{ from, to string }
+	var done []move
+	rollback := func(err error) error {
+		for _, m := range slices.Backward(done) {
+			if rollbackErr := os.Rename(m.to, m.from); rollbackErr != nil {
+				return errors.Join(err, fmt.Errorf("could not restore %v: %v", m.from, rollbackErr))
+			}
+		}
+		return err
+	}
+	tmp := make([]string, len(renames))
+	for i, r := range renames {
+		from := filepath.Join(directory, r.From)
+		tmp[i] = filepath.Join(directory, fmt.Sprintf(".reorder-%v-%v", i, r.From))
+		if err := os.Rename(from, tmp[i]); err != nil {
+			return rollback(fmt.Errorf("could not rename %v: %v", from, err))
+		}
+		done = append(done, move{from, tmp[i]})
+	}
+	for i, r := range renames {
+		to := filepath.Join(directory, r.To)
+		if _, err := os.Stat(to); err == nil {
+			return rollback(fmt.Errorf("could not rename %v to %v: file exists", r.From, r.To))
+		}
+		if err := os.Rename(tmp[i], to); err != nil {
+			return rollback(fmt.Errorf("could not rename %v to %v: %v", r.From, r.To, err))
+		}
+		done = append(done, move{tmp[i], to})
+	}
+	return nil
+}
+
+// Reports whether name starts with a numeric prefix such as 001_.
+func hasNumericPrefix(name string) bool {
+	return numericPrefix.MatchString(name)
+}
@@ -0,0 +1,171 @@
+package microblog
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func markdownFileNames(t *testing.T, d string) []string {
+	matches, err := filepath.Glob(filepath.Join(d, "*.md"))
+	if err != nil {
+		t.Error(err)
+	}
+	var names []string
+	for _, m := range matches {
+		names = append(names, filepath.Base(m))
+	}
+	return names
+}
+
+func TestReorder(t *testing.T) {
+	d := t.TempDir()
+	for _, name := range []string{"1_first.md", "5_second.md", "10_third.md", "new.md"} {
+		os.WriteFile(filepath.Join(d, name), []byte("## "+name+"\nhey"), 0644)
+	}
+
+	// dry run doesn't touch the files
+	renames, err := Reorder(d, true)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := []Rename{
+		{"1_first.md", "001_first.md"},
+		{"5_second.md", "002_second.md"},
+		{"10_third.md", "003_third.md"},
+		{"new.md", "004_new.md"},
+	}
+	if !slices.Equal(renames, expected) {
+		t.Errorf("expected renames %v, got %v", expected, renames)
+	}
+	if names := markdownFileNames(t, d); !slices.Contains(names, "1_first.md") {
+		t.Errorf("expected dry run to keep file names, got %v", names)
+	}
+
+	if _, err := Reorder(d, false); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expectedNames := []string{"001_first.md", "002_second.md", "003_third.md", "004_new.md"}
+	if names := markdownFileNames(t, d); !slices.Equal(names, expectedNames) {
+		t.Errorf("expected files %v, got %v", expectedNames, names)
+	}
+
+	// nothing to do
+	if renames, err := Reorder(d, false); err != nil || len(renames) != 0 {
+		t.Errorf("expected no renames, got %v (%v)", renames, err)
+	}
+}
+
+func TestReorderMoveAndInsert(t *testing.T) {
+	d := t.TempDir()
+	for _, name := range []string{"001_first.md", "002_second.md", "003_third.md", "new.md"} {
+		os.WriteFile(filepath.Join(d, name), []byte("## "+name+"\nhey"), 0644)
+	}
+
+	if _, err := Reorder(d, false, InsertPost("new.md", 2)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected := []string{"001_first.md", "002_new.md", "003_second.md", "004_third.md"}
+	if names := markdownFileNames(t, d); !slices.Equal(names, expected) {
+		t.Errorf("expected files %v, got %v", expected, names)
+	}
+
+	if _, err := Reorder(d, false, MovePost("004_third.md", 1)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expected = []string{"001_third.md", "002_first.md", "003_new.md", "004_second.md"}
+	if names := markdownFileNames(t, d); !slices.Equal(names, expected) {
+		t.Errorf("expected files %v, got %v", expected, names)
+	}
+
+	if _, err := Reorder(d, false, InsertPost("001_third.md", 2)); err == nil {
+		t.Error("expected error when inserting a post that already has a prefix")
+	}
+	if _, err := Reorder(d, false, MovePost("missing.md", 2)); err == nil {
+		t.Error("expected error when moving a post that doesn't exist")
+	}
+	if _, err := Reorder(d, false, MovePost("001_third.md", 6)); err == nil {
+		t.Error("expected error when moving a post out of range")
+	}
+}
+
+func TestReorderMigratesRegistry(t *testing.T) {
+	d := t.TempDir()
+	// swapping the two posts requires temporary names both in the registry and in the file system
+	os.WriteFile(filepath.Join(d, "001_post.md"), []byte("## First\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "002_post.md"), []byte("## Second\nhey"), 0644)
+
+	registry, err := (&sqlitePool).Acquire(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, err := registry.DB.Exec("INSERT INTO posts VALUES ('001_post.md', '2024-01-01'), ('002_post.md', '2024-02-01');"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, err := registry.DB.Exec("INSERT INTO render_cache VALUES ('001_post.md', 'a', 'first'), ('002_post.md', 'b', 'second'), ('other.md', 'c', 'other');"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Reorder(d, false, MovePost("002_post.md", 1)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	content, err := os.ReadFile(filepath.Join(d, "001_post.md"))
+	if err != nil || string(content) != "## Second\nhey" {
+		t.Errorf("expected 001_post.md to contain the second post, got %q (%v)", content, err)
+	}
+	for name, date := range map[string]time.Time{
+		"001_post.md": time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		"002_post.md": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		post, err := NewBlogPost(filepath.Join(d, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		dt, err := registry.GetPublicationDate(post)
+		if err != nil {
+			t.Error(err)
+		}
+		if dt == nil || *dt != date {
+			t.Errorf("expected publication date of %v to be %v, got %v", name, date, dt)
+		}
+	}
+
+	// the cached html of the renamed posts is removed, the cache of other posts is kept
+	var cached []string
+	rows, err := registry.DB.Query("SELECT name FROM render_cache ORDER BY name;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		cached = append(cached, name)
+	}
+	if !slices.Equal(cached, []string{"other.md"}) {
+		t.Errorf("expected only the cached html of other.md to be kept, got %v", cached)
+	}
+}
+
+func TestReorderWithManifest(t *testing.T) {
+	d := t.TempDir()
+	os.WriteFile(filepath.Join(d, "post.md"), []byte("## Title\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, ManifestText), []byte("post.md\n"), 0644)
+
+	if _, err := Reorder(d, false); err == nil {
+		t.Error("expected error when the blog directory contains a manifest")
+	}
+}
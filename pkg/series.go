@@ -0,0 +1,68 @@
+package microblog
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// A series of posts that belong together, declared with `series` (and optionally `seriesPosition`) in the
+// front matter of the posts.
+type Series struct {
+	Name  string     // name as written in the front matter of the first post of the series
+	Slug  string     // URL-safe identifier of the series
+	Posts []BlogPost // posts in series order
+}
+
+// Returns the URL of the overview page of the series, e.g. /series/getting-started/.
+func (s *Series) URL() string {
+	return fmt.Sprintf("/series/%v/", s.Slug)
+}
+
+// Returns the position of post within the series, starting at 1, or 0 if it isn't part of the series.
+func (s *Series) Position(post BlogPost) int {
+	if rendered, ok := post.(RenderedPost); ok {
+		post = rendered.BlogPost
+	}
+	return slices.Index(s.Posts, post) + 1
+}
+
+// Groups the posts by the series they declare and links them to their neighbours within the series.
+// Posts are ordered by their seriesPosition, posts without position come last. Ties keep the order of posts.
+func linkSeries(posts []*blogPost) {
+	var series []*Series
+	members := make(map[string][]*blogPost)
+	for _, p := range posts {
+		p.series, p.prev, p.next = nil, nil, nil
+		slug := slugify(p.SeriesName)
+		if slug == "" {
+			continue
+		}
+		if _, ok := members[slug]; !ok {
+			series = append(series, &Series{Name: p.SeriesName, Slug: slug})
+		}
+		members[slug] = append(members[slug], p)
+	}
+	for _, s := range series {
+		m := members[s.Slug]
+		slices.SortStableFunc(m, func(a, b *blogPost) int {
+			if (a.SeriesPosition == 0) != (b.SeriesPosition == 0) {
+				if a.SeriesPosition == 0 {
+					return 1
+				}
+				return -1
+			}
+			return cmp.Compare(a.SeriesPosition, b.SeriesPosition)
+		})
+		for i, p := range m {
+			p.series = s
+			if i > 0 {
+				p.prev = m[i-1]
+			}
+			if i < len(m)-1 {
+				p.next = m[i+1]
+			}
+			s.Posts = append(s.Posts, p)
+		}
+	}
+}
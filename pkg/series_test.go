@@ -0,0 +1,93 @@
+package microblog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBlogSeries(t *testing.T) {
+	d := t.TempDir()
+
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("---\nseries: Getting Started\nseriesPosition: 2\n---\n## Part *two*\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("---\nseries: Getting Started\n---\n## Appendix\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "c.md"), []byte("---\nseries: getting started\nseriesPosition: 1\n---\n## Part one\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "d.md"), []byte("## Standalone\nhey"), 0644)
+
+	blog, err := NewBlog(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	series := blog.Series()
+	if len(series) != 1 {
+		t.Errorf("expected exactly one series, got %v", series)
+		t.FailNow()
+	}
+	s := series[0]
+	if s.Name != "Getting Started" || s.Slug != "getting-started" || s.URL() != "/series/getting-started/" {
+		t.Errorf("unexpected series %+v", s)
+	}
+	var names []string
+	for _, p := range s.Posts {
+		names = append(names, p.GetName())
+	}
+	if !slices.Equal(names, []string{"c.md", "a.md", "b.md"}) {
+		t.Errorf("expected series order c.md, a.md, b.md, got %v", names)
+	}
+
+	first, second, last := s.Posts[0], s.Posts[1], s.Posts[2]
+	if first.Prev() != nil || first.Next() != second || second.Prev() != first || second.Next() != last || last.Next() != nil {
+		t.Error("expected posts to be linked to their neighbours within the series")
+	}
+	if s.Position(second) != 2 {
+		t.Errorf("expected position 2, got %v", s.Position(second))
+	}
+	if second.GetTitle() != "Part two" {
+		t.Errorf("expected title 'Part two', got %q", second.GetTitle())
+	}
+
+	standalone := blog.GetBlogPosts()[3]
+	if standalone.Series() != nil || standalone.Prev() != nil || standalone.Next() != nil {
+		t.Error("expected standalone post not to be part of a series")
+	}
+}
+
+func TestBlogSeriesTemplate(t *testing.T) {
+	d := t.TempDir()
+
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("---\nseries: Tour\nseriesPosition: 1\n---\n## First\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("---\nseries: Tour\nseriesPosition: 2\n---\n## Second\nhey"), 0644)
+
+	blog, err := NewBlog(d, WithTemplateString(`{{.Series.Name}}:{{with .Prev}}<a href="{{.GetURL}}">{{.GetTitle}}</a>{{end}}|{{with .Next}}<a href="{{.GetURL}}">{{.GetTitle}}</a>{{end}};`))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	html, err := blog.RenderPosts()
+	if err != nil {
+		t.Error(err)
+	}
+	expected := `Tour:|<a href="/posts/b/">Second</a>;Tour:<a href="/posts/a/">First</a>|;`
+	if string(html) != expected {
+		t.Errorf("expected %v, got %s", expected, html)
+	}
+
+	// default template
+	blog, err = NewBlog(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	var buf bytes.Buffer
+	if err := blog.GetBlogPosts()[1].WriteHtml(&buf); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(buf.String(), `Part 2 of the series <a href="/series/tour/">Tour</a>`) {
+		t.Errorf("expected default template to reference the series, got %v", buf.String())
+	}
+}
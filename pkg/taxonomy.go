@@ -0,0 +1,135 @@
+package microblog
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Names of the taxonomies posts can be classified with in their front matter.
+const (
+	TaxonomyTags       = "tags"
+	TaxonomyCategories = "categories"
+)
+
+// A tag or category of a blog post.
+type Term struct {
+	Taxonomy string // TaxonomyTags or TaxonomyCategories
+	Name     string // name as written in the front matter of the first post using the term
+	Slug     string // URL-safe identifier, terms with the same slug are considered equal
+	Count    int    // number of posts with this term, only set for terms returned by Blog.Tags/Blog.Categories
+}
+
+// Returns the URL of the page listing all posts with this term, e.g. /tags/go/.
+func (t Term) URL() string {
+	return fmt.Sprintf("/%v/%v/", t.Taxonomy, t.Slug)
+}
+
+// Converts s to a lower case, URL-safe identifier: letters and digits are kept, all other characters are
+// collapsed into single dashes.
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// Converts the names of a taxonomy listed in the front matter to terms, skipping duplicates.
+func newTerms(taxonomy string, names []string) []Term {
+	terms := make([]Term, 0, len(names))
+	for _, name := range names {
+		slug := slugify(name)
+		if slug == "" || slices.ContainsFunc(terms, func(t Term) bool { return t.Slug == slug }) {
+			continue
+		}
+		terms = append(terms, Term{Taxonomy: taxonomy, Name: strings.TrimSpace(name), Slug: slug})
+	}
+	return terms
+}
+
+// Returns the terms used by posts, sorted by slug, with the number of posts per term.
+func collectTerms(posts []BlogPost, terms func(BlogPost) []Term) []Term {
+	var collected []Term
+	for _, p := range posts {
+		for _, t := range terms(p) {
+			i := slices.IndexFunc(collected, func(c Term) bool { return c.Slug == t.Slug })
+			if i == -1 {
+				collected = append(collected, t)
+				i = len(collected) - 1
+			}
+			collected[i].Count++
+		}
+	}
+	slices.SortFunc(collected, func(a, b Term) int {
+		return strings.Compare(a.Slug, b.Slug)
+	})
+	return collected
+}
+
+// Returns the posts that have a term with the same slug as name, in the order of the blog.
+func filterByTerm(posts []BlogPost, name string, terms func(BlogPost) []Term) []BlogPost {
+	slug := slugify(name)
+	var filtered []BlogPost
+	for _, p := range posts {
+		if slices.ContainsFunc(terms(p), func(t Term) bool { return t.Slug == slug }) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// Renders an overview of the terms of a taxonomy as a list of links. Every item gets a class tag-weight-1 to
+// tag-weight-5 depending on how many posts use the term, which can be used to style a tag cloud.
+func renderTermCloud(taxonomy string, terms []Term) template.HTML {
+	maxCount := 0
+	for _, t := range terms {
+		maxCount = max(maxCount, t.Count)
+	}
+	var s strings.Builder
+	fmt.Fprintf(&s, `<ul class="tag-cloud %v">`, taxonomy)
+	for _, t := range terms {
+		weight := 1
+		if maxCount > 1 {
+			weight = 1 + (t.Count-1)*4/(maxCount-1)
+		}
+		fmt.Fprintf(&s, `<li class="tag-weight-%v"><a href="%v">%v</a> <span class="count">%v</span></li>`,
+			weight, html.EscapeString(t.URL()), html.EscapeString(t.Name), t.Count)
+	}
+	s.WriteString("</ul>")
+	return template.HTML(s.String())
+}
+
+// Writes the overview page of a taxonomy (e.g. tags/index.html) and a page listing the posts of each term
+// (e.g. tags/go/index.html) to outputDirectory. Nothing is written if there are no terms.
+func writeTaxonomyPages(r postRenderer, tmpl *pageTemplate, outputDirectory string, taxonomy string, terms []Term, postsByTerm func(string) []BlogPost) error {
+	if len(terms) == 0 {
+		return nil
+	}
+	if err := writePage(tmpl, filepath.Join(outputDirectory, taxonomy, "index.html"), tmpl.singlePage(renderTermCloud(taxonomy, terms))); err != nil {
+		return err
+	}
+	for _, t := range terms {
+		termPage, err := r.listing(tmpl, postsByTerm(t.Name), newPagination("/", 1, 1))
+		if err != nil {
+			return fmt.Errorf("error when trying to render html for %v %v: %w", taxonomy, t.Name, err)
+		}
+		if err := writePage(tmpl, filepath.Join(outputDirectory, taxonomy, t.Slug, "index.html"), termPage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
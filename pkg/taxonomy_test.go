@@ -0,0 +1,103 @@
+package microblog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	for s, expected := range map[string]string{
+		"Go":                  "go",
+		"  Web Development ":  "web-development",
+		"C++ & Rust!":         "c-rust",
+		"Ünïcode Straße":      "ünïcode-straße",
+		"--already-slugged--": "already-slugged",
+		"!!!":                 "",
+	} {
+		if slug := slugify(s); slug != expected {
+			t.Errorf("expected slug of %q to be %q, got %q", s, expected, slug)
+		}
+	}
+}
+
+func TestBlogTags(t *testing.T) {
+	d := t.TempDir()
+
+	os.WriteFile(filepath.Join(d, "a.md"), []byte("---\ntags: [Go, web]\ncategories: [notes]\n---\n## A\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "b.md"), []byte("---\ntags: [go, go]\n---\n## B\nhey"), 0644)
+	os.WriteFile(filepath.Join(d, "c.md"), []byte("## C\nhey"), 0644)
+
+	blog, err := NewBlog(d)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	tags := blog.Tags()
+	expected := []Term{
+		{Taxonomy: TaxonomyTags, Name: "Go", Slug: "go", Count: 2},
+		{Taxonomy: TaxonomyTags, Name: "web", Slug: "web", Count: 1},
+	}
+	if !slices.Equal(tags, expected) {
+		t.Errorf("expected tags %v, got %v", expected, tags)
+	}
+	if tags[0].URL() != "/tags/go/" {
+		t.Errorf("expected tag url /tags/go/, got %v", tags[0].URL())
+	}
+
+	var names []string
+	for _, p := range blog.PostsByTag("GO") {
+		names = append(names, p.GetName())
+	}
+	if !slices.Equal(names, []string{"a.md", "b.md"}) {
+		t.Errorf("expected posts a.md and b.md to be tagged with go, got %v", names)
+	}
+	if len(blog.PostsByTag("unknown")) != 0 {
+		t.Error("expected no posts for unknown tag")
+	}
+
+	categories := blog.Categories()
+	if len(categories) != 1 || categories[0].Name != "notes" || categories[0].URL() != "/categories/notes/" {
+		t.Errorf("expected category notes, got %v", categories)
+	}
+	if posts := blog.PostsByCategory("notes"); len(posts) != 1 || posts[0].GetName() != "a.md" {
+		t.Errorf("expected post a.md in category notes, got %v", posts)
+	}
+}
+
+func TestBlogpostTagsTemplate(t *testing.T) {
+	d := t.TempDir()
+
+	postFp := filepath.Join(d, "test.md")
+	os.WriteFile(postFp, []byte("---\ntags: [Web Development]\n---\n## Title\nhey"), 0644)
+
+	post, err := NewBlogPost(postFp)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	var html bytes.Buffer
+	if err := post.WriteHtml(&html); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(html.String(), `<ul class="tags"><li><a href="/tags/web-development/">Web Development</a></li></ul>`) {
+		t.Errorf("expected html to list the tags of the post, got %s", html.Bytes())
+	}
+
+	post, err = NewBlogPost(postFp, WithTemplateString(`{{range .Tags}}[{{.}}]{{end}}`))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	html.Reset()
+	if err := post.WriteHtml(&html); err != nil {
+		t.Error(err)
+	}
+	if html.String() != "[Web Development]" {
+		t.Errorf("expected tags to be accessible in the template, got %s", html.Bytes())
+	}
+}
@@ -0,0 +1,208 @@
+package microblog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"maps"
+	"path"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// A post template that is loaded and parsed only once, however many posts it is applied to. The template options
+// create one postTemplate per option value, which is combined with the partials and functions of the post when
+// the post is created (see combineTemplate), so all posts of a Blog share the parsed template.
+type postTemplate struct {
+	once     sync.Once
+	load     func() (string, error)
+	partials *templatePartials // templates parsed together with the template, may be nil
+	funcs    *templateFuncs    // functions in addition to TemplateFuncs, may be nil
+	baseURL  *templateBaseURL  // base URL of absURL, may be nil
+	tmpl     *template.Template
+	hash     string // hash of the template text, the partials and the functions, part of the key of the render cache
+	err      error
+}
+
+func newPostTemplate(load func() (string, error)) *postTemplate {
+	return &postTemplate{load: load}
+}
+
+// Loads and parses the template on the first call, and returns the error of the first call on subsequent calls.
+func (t *postTemplate) parse() error {
+	t.once.Do(func() {
+		text, err := t.load()
+		if err != nil {
+			t.err = err
+			return
+		}
+		var partials []partial
+		if t.partials != nil {
+			if partials, err = t.partials.read(); err != nil {
+				t.err = err
+				return
+			}
+		}
+		var baseURL string
+		if t.baseURL != nil {
+			baseURL = t.baseURL.url
+		}
+		funcs := TemplateFuncs(baseURL)
+		if t.funcs != nil {
+			maps.Copy(funcs, t.funcs.funcs)
+		}
+		h := sha256.New()
+		h.Write([]byte(text))
+		for _, p := range partials {
+			fmt.Fprintf(h, "\x00%v\x00%v", p.name, p.text)
+		}
+		// functions can't be compared, but the base URL of absURL and the names of the functions can
+		fmt.Fprintf(h, "\x00%v", baseURL)
+		for _, name := range slices.Sorted(maps.Keys(funcs)) {
+			fmt.Fprintf(h, "\x00%v", name)
+		}
+		t.hash = hex.EncodeToString(h.Sum(nil))
+		t.tmpl, t.err = parsePostTemplate(text, funcs, partials...)
+	})
+	return t.err
+}
+
+// Parses the text of a post template together with the partials and checks its field references against
+// RenderedPost. The partials are parsed first, so the template can redefine their blocks. All templates can use
+// the functions funcs.
+func parsePostTemplate(text string, funcs template.FuncMap, partials ...partial) (*template.Template, error) {
+	tmpl := template.New("post").Funcs(funcs)
+	for _, p := range partials {
+		if _, err := tmpl.New(p.name).Parse(p.text); err != nil {
+			return nil, fmt.Errorf("could not open template %v: %v", p.name, err)
+		}
+	}
+	tmpl, err := tmpl.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("could not open template: %v", err)
+	}
+	if tmpl.Tree == nil {
+		return nil, errors.New("template tree is empty")
+	}
+	if err := validateTemplate(tmpl, reflect.TypeFor[RenderedPost]()); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Returns the parsed template of a post from the template options applied to it: base, or the default template
+// if base is nil, parsed together with partials, funcs and baseURL, any of which may be nil. Posts with the same
+// options share the template.
+func combineTemplate(base *postTemplate, partials *templatePartials, funcs *templateFuncs, baseURL *templateBaseURL) (*postTemplate, error) {
+	if base == nil {
+		base = defaultPostTemplate()
+	}
+	var cache *templateCache
+	switch {
+	case partials != nil:
+		cache = &partials.cache
+	case funcs != nil:
+		cache = &funcs.cache
+	case baseURL != nil:
+		cache = &baseURL.cache
+	default:
+		return base, base.parse()
+	}
+	return cache.combine(templateKey{base, partials, funcs, baseURL})
+}
+
+type templateKey struct {
+	base     *postTemplate
+	partials *templatePartials
+	funcs    *templateFuncs
+	baseURL  *templateBaseURL
+}
+
+// Combined templates, held by the partials, functions or base URL they have been combined with, so that they live
+// only as long as the options.
+type templateCache struct {
+	mu        sync.Mutex
+	templates map[templateKey]*postTemplate
+}
+
+// Returns the template key.base parsed together with key.partials, key.funcs and key.baseURL.
+func (c *templateCache) combine(key templateKey) (*postTemplate, error) {
+	c.mu.Lock()
+	combined, ok := c.templates[key]
+	if !ok {
+		if c.templates == nil {
+			c.templates = make(map[templateKey]*postTemplate)
+		}
+		combined = &postTemplate{load: key.base.load, partials: key.partials, funcs: key.funcs, baseURL: key.baseURL}
+		c.templates[key] = combined
+	}
+	c.mu.Unlock()
+	return combined, combined.parse()
+}
+
+// the default template at its current value, DefaultOptions.Template may be changed at any time
+var defaultTemplate struct {
+	sync.Mutex
+	text string
+	tmpl *postTemplate
+}
+
+// Returns the template for DefaultOptions.Template, creating a new one only if it has changed since the last call.
+func defaultPostTemplate() *postTemplate {
+	defaultTemplate.Lock()
+	defer defaultTemplate.Unlock()
+	if defaultTemplate.tmpl == nil || defaultTemplate.text != DefaultOptions.Template {
+		text := DefaultOptions.Template
+		defaultTemplate.text = text
+		defaultTemplate.tmpl = newPostTemplate(func() (string, error) { return text, nil })
+	}
+	return defaultTemplate.tmpl
+}
+
+// A template file parsed together with a post template, e.g. a layout or a partial, see WithPartials.
+type partial struct {
+	name string // base name of the file, the name of the template
+	text string
+}
+
+// Layouts and partials added to post templates by WithPartials.
+type templatePartials struct {
+	fsys     fs.FS
+	patterns []string
+	cache    templateCache
+}
+
+// Returns the files matching the patterns, in the order of the patterns and sorted by name per pattern.
+func (p *templatePartials) read() ([]partial, error) {
+	var partials []partial
+	for _, pattern := range p.patterns {
+		matches, err := fs.Glob(p.fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %v: %v", pattern, err)
+		}
+		for _, name := range matches {
+			text, err := fs.ReadFile(p.fsys, name)
+			if err != nil {
+				return nil, fmt.Errorf("could not read template %v: %v", name, err)
+			}
+			partials = append(partials, partial{name: path.Base(name), text: string(text)})
+		}
+	}
+	return partials, nil
+}
+
+// Functions added to post templates by WithTemplateFuncs.
+type templateFuncs struct {
+	funcs template.FuncMap
+	cache templateCache
+}
+
+// Base URL of absURL in post templates, set by WithBaseURL.
+type templateBaseURL struct {
+	url   string
+	cache templateCache
+}
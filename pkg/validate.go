@@ -0,0 +1,286 @@
+package microblog
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"html/template"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template/parse"
+)
+
+// Checks the field and method references of tmpl against the type of the data the template is executed with,
+// e.g. RenderedPost for post templates, so that a template referencing {{.Title}} is rejected when it is parsed
+// rather than when it is executed for the first post. Returns all unknown references joined with errors.Join,
+// each with the position in the template and the most similar known names. References that can't be resolved
+// statically, e.g. fields of values returned by functions or of interfaces, are not checked.
+func validateTemplate(tmpl *template.Template, data reflect.Type) error {
+	v := &templateValidator{tmpl: tmpl, visited: make(map[string]bool)}
+	v.walk(tmpl.Tree, data, tmpl.Tree.Root, map[string]reflect.Type{"$": data})
+	return errors.Join(v.problems...)
+}
+
+type templateValidator struct {
+	tmpl     *template.Template
+	visited  map[string]bool // templates invoked with {{template}}, by name and type of dot
+	problems []error
+}
+
+// Checks node of tree, executed with dot of type dot and the variables vars. A nil type is unknown.
+func (v *templateValidator) walk(tree *parse.Tree, dot reflect.Type, node parse.Node, vars map[string]reflect.Type) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			v.walk(tree, dot, c, vars)
+		}
+	case *parse.ActionNode:
+		t := v.pipe(tree, dot, n.Pipe, vars)
+		for _, d := range n.Pipe.Decl {
+			vars[d.Ident[0]] = t
+		}
+	case *parse.IfNode:
+		scope := maps.Clone(vars)
+		v.pipe(tree, dot, n.Pipe, scope)
+		v.walk(tree, dot, n.List, scope)
+		v.walk(tree, dot, n.ElseList, maps.Clone(vars))
+	case *parse.WithNode:
+		scope := maps.Clone(vars)
+		t := v.pipe(tree, dot, n.Pipe, scope)
+		for _, d := range n.Pipe.Decl {
+			scope[d.Ident[0]] = t
+		}
+		v.walk(tree, t, n.List, scope)
+		v.walk(tree, dot, n.ElseList, maps.Clone(vars))
+	case *parse.RangeNode:
+		scope := maps.Clone(vars)
+		key, elem := rangeTypes(v.pipe(tree, dot, n.Pipe, scope))
+		switch len(n.Pipe.Decl) {
+		case 1:
+			scope[n.Pipe.Decl[0].Ident[0]] = elem
+		case 2:
+			scope[n.Pipe.Decl[0].Ident[0]] = key
+			scope[n.Pipe.Decl[1].Ident[0]] = elem
+		}
+		v.walk(tree, elem, n.List, scope)
+		v.walk(tree, dot, n.ElseList, maps.Clone(vars))
+	case *parse.TemplateNode:
+		var t reflect.Type
+		if n.Pipe != nil {
+			t = v.pipe(tree, dot, n.Pipe, vars)
+		}
+		v.template(n.Name, t)
+	}
+}
+
+// Checks the template name invoked with dot of type dot, once per name and type.
+func (v *templateValidator) template(name string, dot reflect.Type) {
+	key := fmt.Sprintf("%v %v", name, dot)
+	if v.visited[key] || dot == nil {
+		return
+	}
+	v.visited[key] = true
+	if t := v.tmpl.Lookup(name); t != nil && t.Tree != nil {
+		v.walk(t.Tree, dot, t.Tree.Root, map[string]reflect.Type{"$": dot})
+	}
+}
+
+// Checks a pipeline and returns the type of its result.
+func (v *templateValidator) pipe(tree *parse.Tree, dot reflect.Type, pipe *parse.PipeNode, vars map[string]reflect.Type) reflect.Type {
+	if pipe == nil {
+		return nil
+	}
+	var t reflect.Type
+	for _, cmd := range pipe.Cmds {
+		t = v.command(tree, dot, cmd, vars)
+	}
+	return t
+}
+
+// Checks the arguments of a command and returns the type of its result.
+func (v *templateValidator) command(tree *parse.Tree, dot reflect.Type, cmd *parse.CommandNode, vars map[string]reflect.Type) reflect.Type {
+	var t reflect.Type
+	for i, arg := range cmd.Args {
+		argType := v.arg(tree, dot, arg, vars)
+		if i == 0 {
+			t = argType
+		}
+	}
+	if _, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		return nil // result of a function
+	}
+	return t
+}
+
+// Checks an argument of a command and returns its type.
+func (v *templateValidator) arg(tree *parse.Tree, dot reflect.Type, arg parse.Node, vars map[string]reflect.Type) reflect.Type {
+	switch n := arg.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return v.fields(tree, n, dot, n.Ident)
+	case *parse.VariableNode:
+		return v.fields(tree, n, vars[n.Ident[0]], n.Ident[1:])
+	case *parse.ChainNode:
+		return v.fields(tree, n, v.arg(tree, dot, n.Node, vars), n.Field)
+	case *parse.PipeNode:
+		return v.pipe(tree, dot, n, vars)
+	}
+	return nil
+}
+
+// Resolves the chain of field or method names on a value of type t and returns the type of the result. Unknown
+// names are reported with the position of node.
+func (v *templateValidator) fields(tree *parse.Tree, node parse.Node, t reflect.Type, names []string) reflect.Type {
+	for _, name := range names {
+		if t == nil {
+			return nil
+		}
+		next, ok := fieldType(t, name)
+		if !ok {
+			location, _ := tree.ErrorContext(node)
+			err := fmt.Sprintf("template: %v: can't evaluate field %v in type %v", location, name, t)
+			if suggestions := similarNames(name, fieldNames(t)); len(suggestions) > 0 {
+				err += fmt.Sprintf(", did you mean %v?", strings.Join(suggestions, " or "))
+			}
+			v.problems = append(v.problems, errors.New(err))
+			return nil
+		}
+		t = next
+	}
+	return t
+}
+
+// Returns the type of the field or method name of a value of type t, and whether t has such a field or method.
+// The type is nil if it can't be determined statically, e.g. for maps and interfaces without the method.
+func fieldType(t reflect.Type, name string) (reflect.Type, bool) {
+	if m, ok := methodSet(t).MethodByName(name); ok {
+		return resultType(m.Type), true
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		f, ok := t.FieldByName(name)
+		if !ok || !f.IsExported() {
+			return nil, false
+		}
+		return f.Type, true
+	case reflect.Map:
+		return t.Elem(), true
+	case reflect.Interface: // the dynamic value may have further fields or methods
+		return nil, true
+	}
+	return nil, false
+}
+
+// Returns the type whose methods can be called on a value of type t. Methods with pointer receiver are included,
+// as the value may be addressable when the template is executed.
+func methodSet(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Interface || t.Kind() == reflect.Pointer {
+		return t
+	}
+	return reflect.PointerTo(t)
+}
+
+// Returns the type of the first result of a method, or nil for methods without result.
+func resultType(method reflect.Type) reflect.Type {
+	if method.NumOut() == 0 {
+		return nil
+	}
+	return method.Out(0)
+}
+
+// Returns the types of the keys and elements of a range over a value of type t.
+func rangeTypes(t reflect.Type) (reflect.Type, reflect.Type) {
+	if t == nil {
+		return nil, nil
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return reflect.TypeFor[int](), t.Elem()
+	case reflect.Map:
+		return t.Key(), t.Elem()
+	case reflect.Chan:
+		return nil, t.Elem()
+	case reflect.Int:
+		return t, t
+	}
+	return nil, nil
+}
+
+// Returns the names of the exported fields and methods of a value of type t.
+func fieldNames(t reflect.Type) []string {
+	var names []string
+	methods := methodSet(t)
+	for i := range methods.NumMethod() {
+		names = append(names, methods.Method(i).Name)
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		for _, f := range reflect.VisibleFields(t) {
+			if f.IsExported() && !slices.Contains(names, f.Name) {
+				names = append(names, f.Name)
+			}
+		}
+	}
+	return names
+}
+
+// Returns up to three of names that are similar to name, most similar first. Names are compared case-insensitively
+// and without a Get prefix, so that Title suggests GetTitle.
+func similarNames(name string, names []string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, n := range names {
+		lower := strings.ToLower(n)
+		distance := min(levenshtein(strings.ToLower(name), lower), levenshtein(strings.ToLower(name), strings.TrimPrefix(lower, "get")))
+		if distance <= max(2, len(name)/3) {
+			candidates = append(candidates, candidate{n, distance})
+		}
+	}
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(cmp.Compare(a.distance, b.distance), strings.Compare(a.name, b.name))
+	})
+	var similar []string
+	for _, c := range candidates[:min(3, len(candidates))] {
+		similar = append(similar, c.name)
+	}
+	return similar
+}
+
+// Returns the Levenshtein distance of a and b, i.e. the number of inserted, deleted or replaced characters that
+// turn a into b.
+func levenshtein(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	row := make([]int, len(t)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		prev := row[0] // distance of s[:i-1] and t[:j-1]
+		row[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			prev, row[j] = row[j], min(row[j]+1, row[j-1]+1, prev+cost)
+		}
+	}
+	return row[len(t)]
+}
@@ -0,0 +1,63 @@
+package microblog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	valid := []string{
+		DefaultOptions.Template,
+		`{{range $i, $t := .GetTags}}{{$i}} {{$t.Slug}} {{$.Heading}}{{end}}`,
+		`{{with $s := .Series}}{{$s.Name}} {{range .Posts}}{{.GetTitle}}{{end}}{{end}}`,
+		`{{$date := .Published}}{{$date.Year}} {{.Published.Format "2006"}}`,
+		`{{(.Series).Name}} {{len .Tags | printf "%v"}} {{index .Tags 0}}`,
+		`{{.Prev.Anything}} {{(printf "%v" .).Anything}}`, // interfaces and results of functions aren't checked
+		`{{define "tags"}}{{range .}}{{.URL}}{{end}}{{end}}{{template "tags" .GetTags}}`,
+		`{{range 3}}{{.}}{{end}}`,
+	}
+	for _, text := range valid {
+		if _, err := parsePostTemplate(text, TemplateFuncs("")); err != nil {
+			t.Errorf("expected %q to be valid, got %v", text, err)
+		}
+	}
+
+	for text, expected := range map[string]string{
+		`<h2>{{.Title}}</h2>`:                                    "post:1:6: can't evaluate field Title in type microblog.RenderedPost, did you mean GetTitle?",
+		`{{with .Series}}{{.Nme}}{{end}}`:                        "can't evaluate field Nme in type *microblog.Series, did you mean Name?",
+		`{{range .GetTags}}{{.Slg}}{{end}}`:                      "can't evaluate field Slg in type microblog.Term, did you mean Slug?",
+		`{{$d := .Published}}{{$d.Yaer}}`:                        "can't evaluate field Yaer in type time.Time, did you mean Year?",
+		"\n{{.Heading}}\n{{.Contnt}}":                            "post:3:2: can't evaluate field Contnt in type microblog.RenderedPost, did you mean Content?",
+		`{{define "x"}}{{.Foo}}{{end}}{{template "x" .GetTags}}`: "can't evaluate field Foo in type []microblog.Term",
+		`{{.Xyzzy}}`: "can't evaluate field Xyzzy in type microblog.RenderedPost",
+	} {
+		_, err := parsePostTemplate(text, TemplateFuncs(""))
+		if err == nil || !strings.HasSuffix(err.Error(), expected) {
+			t.Errorf("expected error ending in %q for %q, got %v", expected, text, err)
+		}
+	}
+
+	// all unknown fields are reported
+	_, err := parsePostTemplate(`{{.Title}} {{.Heading}} {{.Body}}`, TemplateFuncs(""))
+	if err == nil || len(strings.Split(err.Error(), "\n")) != 2 {
+		t.Errorf("expected two errors, got %v", err)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"title", "", 5},
+		{"title", "title", 0},
+		{"kitten", "sitting", 3},
+		{"Contnt", "Content", 1},
+		{"Yaer", "Year", 2},
+	} {
+		if d := levenshtein(c.a, c.b); d != c.distance {
+			t.Errorf("expected distance %v between %q and %q, got %v", c.distance, c.a, c.b, d)
+		}
+	}
+}